@@ -0,0 +1,108 @@
+package instorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dgraph-io/badger/v3/pb"
+)
+
+// Reads entries of backup written by DB.Backup one by one without loading it
+// into a database
+type BackupReader struct {
+	r   *bufio.Reader
+	buf []byte
+	// Entries of the last read list, which are not returned yet
+	kvs []*pb.KV
+}
+
+// Creates reader of backup written by DB.Backup. Keys are returned as they
+// are stored by namespaces, for example NamespaceMultiple keys consist of
+// namespace prefix and gob encoded key.
+func OpenBackupReader(r io.Reader) (*BackupReader, error) {
+	br := &BackupReader{
+		r: bufio.NewReaderSize(r, 16<<10),
+	}
+
+	_, err := br.r.Peek(1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("OpenBackupReader: %w", err)
+	}
+
+	return br, nil
+}
+
+// Returns next entry, entries are not ordered by key. Only entries visible
+// after loading backup are returned, deleted and expired ones are skipped.
+// Returns io.EOF when there are no entries left.
+func (br *BackupReader) Next() (RawKeyValue, error) {
+	for len(br.kvs) == 0 {
+		err := br.readList()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return RawKeyValue{}, io.EOF
+			}
+
+			return RawKeyValue{}, fmt.Errorf("Next: %w", err)
+		}
+	}
+
+	kv := br.kvs[0]
+	br.kvs = br.kvs[1:]
+
+	return RawKeyValue{
+		Key:   kv.Key,
+		Value: kv.Value,
+	}, nil
+}
+
+// Reads next list of entries written by badger as little endian length
+// followed by protobuf message
+func (br *BackupReader) readList() error {
+	var size uint64
+	err := binary.Read(br.r, binary.LittleEndian, &size)
+	if err != nil {
+		return err
+	}
+
+	if uint64(cap(br.buf)) < size {
+		br.buf = make([]byte, size)
+	}
+	_, err = io.ReadFull(br.r, br.buf[:size])
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	list := &pb.KVList{}
+	err = list.Unmarshal(br.buf[:size])
+	if err != nil {
+		return err
+	}
+
+	now := uint64(time.Now().Unix())
+	var lastKey []byte
+	for _, kv := range list.Kv {
+		// Versions of the same key follow the newest one, which is the only
+		// version visible after loading backup
+		if lastKey != nil && bytes.Equal(kv.Key, lastKey) {
+			continue
+		}
+		lastKey = kv.Key
+
+		// Deleted entries are marked by the lowest bit of meta
+		if len(kv.Meta) > 0 && kv.Meta[0]&1 != 0 {
+			continue
+		}
+		if kv.ExpiresAt != 0 && kv.ExpiresAt <= now {
+			continue
+		}
+		br.kvs = append(br.kvs, kv)
+	}
+
+	return nil
+}
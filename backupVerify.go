@@ -0,0 +1,83 @@
+package instorage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Reports whether backup written by DB.Backup contains exactly the same keys
+// and values as database. Backup is streamed and compared by fingerprints, so
+// neither of them is loaded into memory and database is not changed. Entries
+// expired by the time of check are not compared.
+func (db *DB[TxnAPIT]) VerifyAgainstBackup(r io.Reader) (bool, error) {
+	br, err := OpenBackupReader(r)
+	if err != nil {
+		return false, fmt.Errorf("VerifyAgainstBackup: %w", err)
+	}
+
+	var backupSum [32]byte
+	for {
+		kv, err := br.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return false, fmt.Errorf("VerifyAgainstBackup: %w", err)
+		}
+
+		addFingerprintEntry(&backupSum, kv.Key, kv.Value)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var dbSum [32]byte
+	err = db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			// Written after backup, so it is never contained in backup
+			if bytes.Equal(item.Key(), lastBackupVersionKey) {
+				continue
+			}
+
+			err := item.Value(func(valueb []byte) error {
+				addFingerprintEntry(&dbSum, item.Key(), valueb)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("VerifyAgainstBackup: %w", err)
+	}
+
+	return backupSum == dbSum, nil
+}
+
+// Adds SHA-256 hash of entry to sum as 256-bit big-endian number. Addition
+// does not depend on order, and backup entries are not ordered by key.
+func addFingerprintEntry(sum *[32]byte, keyb []byte, valueb []byte) {
+	h := sha256.New()
+	writeFingerprintEntry(h, keyb, valueb)
+	entrySum := h.Sum(nil)
+
+	carry := 0
+	for i := len(sum) - 1; i >= 0; i-- {
+		v := int(sum[i]) + int(entrySum[i]) + carry
+		sum[i] = byte(v)
+		carry = v >> 8
+	}
+}
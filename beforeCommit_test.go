@@ -0,0 +1,106 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBeforeCommitRunsHooksInOrder(t *testing.T) {
+	db := openTestDB(t)
+
+	var calls []string
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, int](txn, "counters")
+		txn.BeforeCommit(func() error {
+			calls = append(calls, "first")
+			// Hooks may register more hooks and write within transaction
+			txn.BeforeCommit(func() error {
+				calls = append(calls, "nested")
+				return nsm.Set("nested", 1)
+			})
+			return nil
+		})
+		txn.BeforeCommit(func() error {
+			calls = append(calls, "second")
+			return nil
+		})
+
+		if len(calls) != 0 {
+			t.Errorf("hooks are called before updater returns: %v", calls)
+		}
+		return nsm.Set("a", 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "second", "nested"}
+	if len(calls) != len(want) {
+		t.Fatalf("called hooks %v, expected %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("called hooks %v, expected %v", calls, want)
+		}
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, ok, err := NewNamespaceMultiple[string, int](txn, "counters").Get("nested")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			t.Error("write of hook is not committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBeforeCommitErrorDiscardsTransaction(t *testing.T) {
+	db := openTestDB(t)
+
+	errHook := errors.New("hook failed")
+	err := db.Update(func(txn Txn) error {
+		txn.BeforeCommit(func() error {
+			return errHook
+		})
+		return NewNamespaceMultiple[string, int](txn, "counters").Set("a", 1)
+	})
+	if !errors.Is(err, errHook) {
+		t.Fatalf("Update returned %v, expected error of hook", err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, ok, err := NewNamespaceMultiple[string, int](txn, "counters").Get("a")
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.Error("transaction is committed after hook failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBeforeCommitPanicsInReadOnlyTransaction(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.View(func(txn Txn) error {
+		defer func() {
+			if recover() == nil {
+				t.Error("BeforeCommit does not panic in read-only transaction")
+			}
+		}()
+		txn.BeforeCommit(func() error { return nil })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
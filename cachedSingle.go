@@ -0,0 +1,76 @@
+package instorage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Value of NamespaceSingle bound to database and cached in memory, so hot
+// reads do not start a transaction each time. Safe for concurrent use.
+type CachedSingle[ValueT any] struct {
+	name string
+	// Runs fn within read-only transaction of database
+	view func(fn func(txn Txn) error) error
+
+	mu        sync.Mutex
+	value     ValueT
+	fetchedAt time.Time
+	fetched   bool
+}
+
+// Creates cached reader of NamespaceSingle with passed name. Name must not be
+// empty.
+func NewCachedSingle[TxnAPIT any, ValueT any](db *DB[TxnAPIT], name string) *CachedSingle[ValueT] {
+	Txn{state: db.state}.validateNamespaceName(name)
+	return &CachedSingle[ValueT]{
+		name: name,
+		view: func(fn func(txn Txn) error) error {
+			db.mu.RLock()
+			defer db.mu.RUnlock()
+
+			return db.badgerdb.View(func(badgertxn *badger.Txn) error {
+				return fn(db.newTxn(badgertxn))
+			})
+		},
+	}
+}
+
+// Returns value read from database at most maxAge ago. Only when cached value
+// is older, it is read again within a new transaction. So value written to
+// database may be returned only up to maxAge after it was committed, until
+// then previous value is returned.
+func (cs *CachedSingle[ValueT]) GetCached(maxAge time.Duration) (ValueT, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.fetched && time.Since(cs.fetchedAt) < maxAge {
+		return cs.value, nil
+	}
+
+	var value ValueT
+	err := cs.view(func(txn Txn) error {
+		var err error
+		value, err = NewNamespaceSingle[ValueT](txn, cs.name).Get()
+		return err
+	})
+	if err != nil {
+		return value, fmt.Errorf("GetCached `%v`: %w", cs.name, err)
+	}
+
+	cs.value = value
+	cs.fetchedAt = time.Now()
+	cs.fetched = true
+
+	return value, nil
+}
+
+// Drops cached value, so the next GetCached reads it from database
+func (cs *CachedSingle[ValueT]) Invalidate() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.fetched = false
+}
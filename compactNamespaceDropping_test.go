@@ -0,0 +1,94 @@
+package instorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompactNamespaceDroppingDecodesLikeNamespace(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		primitive := NewNamespaceMultiple[int, int64](txn, "primitive").WithPrimitiveEncoding()
+		shared := NewNamespaceMultiple[int, descriptorPoint](txn, "shared").WithSharedTypeDescriptor()
+		for i := 0; i < 10; i++ {
+			err := primitive.Set(i, int64(i))
+			if err != nil {
+				return err
+			}
+			err = shared.Set(i, descriptorPoint{X: i})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := CompactNamespaceDropping(db, "primitive", func(nsm *NamespaceMultiple[int, int64]) {
+		nsm.WithPrimitiveEncoding()
+	}, func(key int, value int64) bool {
+		return value%2 == 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 5 {
+		t.Errorf("dropped %v primitive values, expected 5", dropped)
+	}
+
+	dropped, err = CompactNamespaceDropping(db, "shared", func(nsm *NamespaceMultiple[int, descriptorPoint]) {
+		nsm.WithSharedTypeDescriptor()
+	}, func(key int, value descriptorPoint) bool {
+		return value.X >= 7
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 3 {
+		t.Errorf("dropped %v shared descriptor values, expected 3", dropped)
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, ok, err := NewNamespaceMultiple[int, int64](txn, "primitive").WithPrimitiveEncoding().Get(1)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			t.Error("kept primitive value is missing")
+		}
+		_, ok, err = NewNamespaceMultiple[int, descriptorPoint](txn, "shared").WithSharedTypeDescriptor().Get(8)
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.Error("dropped shared descriptor value is still stored")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactNamespaceDroppingUntracksExpiry(t *testing.T) {
+	db, advance, expired := openExpiryTestDB(t)
+	fillReservedKeys(t, db, "values")
+
+	dropped, err := CompactNamespaceDropping(db, "values", nil, func(key string, value string) bool {
+		return key == "expiring"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 1 {
+		t.Fatalf("dropped %v pairs, expected 1", dropped)
+	}
+
+	advance(2 * time.Minute)
+	if got := expired(); len(got) != 0 {
+		t.Errorf("dropped pairs are reported expired: %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Compresses and decompresses values stored by namespace with
+// WithCompressAbove. Must be safe for concurrent use.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Headers of values stored with WithCompressAbove
+const (
+	compressHeaderRaw        = 0x00
+	compressHeaderCompressed = 0x01
+)
+
+// Compresses valueb, if it is longer than threshold, and prepends header
+// telling whether it is compressed
+func compressAbove(valueb []byte, threshold int, compressor Compressor) ([]byte, error) {
+	if len(valueb) <= threshold {
+		return append([]byte{compressHeaderRaw}, valueb...), nil
+	}
+
+	compressed, err := compressor.Compress(valueb)
+	if err != nil {
+		return nil, fmt.Errorf("compressAbove: %w", err)
+	}
+
+	return append([]byte{compressHeaderCompressed}, compressed...), nil
+}
+
+// Reverses compressAbove
+func decompressAbove(valueb []byte, compressor Compressor) ([]byte, error) {
+	if len(valueb) == 0 {
+		return nil, errors.New("decompressAbove: missing header")
+	}
+
+	switch valueb[0] {
+	case compressHeaderRaw:
+		return valueb[1:], nil
+	case compressHeaderCompressed:
+		decompressed, err := compressor.Decompress(valueb[1:])
+		if err != nil {
+			return nil, fmt.Errorf("decompressAbove: %w", err)
+		}
+		return decompressed, nil
+	default:
+		return nil, fmt.Errorf("decompressAbove: unknown header %#x", valueb[0])
+	}
+}
@@ -0,0 +1,53 @@
+package instorage
+
+import (
+	"context"
+	"errors"
+)
+
+// Returned by transactions of database opened with WithMaxConcurrentTxns in
+// fail fast mode, when limit of concurrent transactions is reached
+var ErrTooBusy = errors.New("too many concurrent transactions")
+
+// Limits number of transactions started by View, Update and their variants,
+// which run at the same time, to n. When limit is reached, new transactions
+// wait for running ones to finish, or fail with ErrTooBusy if failFast is
+// true. Transactions with timeout wait no longer than their timeout.
+func WithMaxConcurrentTxns(n int, failFast bool) Option {
+	if n < 1 {
+		panic("n must be positive")
+	}
+
+	return func(dbopts *dbOptions) {
+		dbopts.txnSlots = make(chan struct{}, n)
+		dbopts.failFastTxns = failFast
+	}
+}
+
+// Takes slot of concurrent transaction, if their number is limited. Returned
+// function frees the slot.
+func (dbopts *dbOptions) acquireTxnSlot(ctx context.Context) (release func(), err error) {
+	if dbopts.txnSlots == nil {
+		return func() {}, nil
+	}
+
+	release = func() {
+		<-dbopts.txnSlots
+	}
+
+	if dbopts.failFastTxns {
+		select {
+		case dbopts.txnSlots <- struct{}{}:
+			return release, nil
+		default:
+			return nil, ErrTooBusy
+		}
+	}
+
+	select {
+	case dbopts.txnSlots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, Txn{ctx: ctx}.checkContext()
+	}
+}
@@ -0,0 +1,73 @@
+package instorage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Reads values of single namespaces into fields of struct pointed by dst. Each
+// field tagged like `instorage:"name"` receives value of NamespaceSingle with
+// that name, other fields are ignored. Fields of namespaces with no value
+// stored are left unchanged, so dst may be filled with defaults beforehand.
+// Values must be stored with gob, which is the default for NamespaceSingle.
+func LoadConfig(txn Txn, dst any) error {
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Pointer || dstValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("LoadConfig: dst must be pointer to struct, got %T", dst)
+	}
+	structValue := dstValue.Elem()
+	structType := structValue.Type()
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, ok := field.Tag.Lookup("instorage")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() {
+			return fmt.Errorf("LoadConfig: field %v is not exported", field.Name)
+		}
+		txn.validateNamespaceName(name)
+
+		err := loadConfigField(txn, name, structValue.Field(i))
+		if err != nil {
+			return fmt.Errorf("LoadConfig `%v`: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func loadConfigField(txn Txn, name string, fieldValue reflect.Value) error {
+	item, err := txn.badgertxn.Get(txn.singleKey(name))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		return err
+	}
+	if txn.isExpired(item) {
+		return nil
+	}
+
+	return item.Value(func(valueb []byte) error {
+		decoded := reflect.New(fieldValue.Type())
+		r := bytes.NewReader(valueb)
+		err := gob.NewDecoder(r).DecodeValue(decoded)
+		if err != nil {
+			return fmt.Errorf("decodeGob: %w", err)
+		}
+		if r.Len() != 0 {
+			return fmt.Errorf("decodeGob: %w: %v of %v bytes left unread", ErrTrailingData, r.Len(), len(valueb))
+		}
+
+		fieldValue.Set(decoded.Elem())
+		return nil
+	})
+}
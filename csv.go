@@ -0,0 +1,225 @@
+package instorage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// Returned by ExportCSV and ImportCSV for keys and values, which can not be
+// represented as CSV columns
+var ErrUnsupportedCSVType = errors.New("type is not supported in CSV")
+
+// Writes all key-value pairs as CSV with header row. Keys and values must be
+// of basic types or flat structs of basic types, each struct field becomes a
+// separate column named like Key.Field or Value.Field.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ExportCSV(w io.Writer) error {
+	keyColumns, err := csvColumns(reflect.TypeOf((*KeyT)(nil)).Elem(), "Key")
+	if err != nil {
+		return fmt.Errorf("ExportCSV `%v`: %w", nsm.name, err)
+	}
+	valueColumns, err := csvColumns(reflect.TypeOf((*ValueT)(nil)).Elem(), "Value")
+	if err != nil {
+		return fmt.Errorf("ExportCSV `%v`: %w", nsm.name, err)
+	}
+
+	cw := csv.NewWriter(w)
+
+	err = cw.Write(append(keyColumns.names(), valueColumns.names()...))
+	if err != nil {
+		return fmt.Errorf("ExportCSV `%v`: %w", nsm.name, err)
+	}
+
+	err = nsm.Iter(func(key KeyT, value ValueT) (bool, error) {
+		record := keyColumns.format(reflect.ValueOf(key))
+		record = append(record, valueColumns.format(reflect.ValueOf(value))...)
+		return false, cw.Write(record)
+	})
+	if err != nil {
+		return fmt.Errorf("ExportCSV: %w", err)
+	}
+
+	cw.Flush()
+
+	err = cw.Error()
+	if err != nil {
+		return fmt.Errorf("ExportCSV `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Reads CSV written by ExportCSV and sets all key-value pairs from it. Header
+// row must have the same columns as written by ExportCSV.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ImportCSV(r io.Reader) error {
+	keyColumns, err := csvColumns(reflect.TypeOf((*KeyT)(nil)).Elem(), "Key")
+	if err != nil {
+		return fmt.Errorf("ImportCSV `%v`: %w", nsm.name, err)
+	}
+	valueColumns, err := csvColumns(reflect.TypeOf((*ValueT)(nil)).Elem(), "Value")
+	if err != nil {
+		return fmt.Errorf("ImportCSV `%v`: %w", nsm.name, err)
+	}
+
+	names := append(keyColumns.names(), valueColumns.names()...)
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(names)
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("ImportCSV `%v`: %w", nsm.name, err)
+	}
+	for i, name := range names {
+		if header[i] != name {
+			return fmt.Errorf("ImportCSV `%v`: column %v is `%v`, expected `%v`", nsm.name, i+1, header[i], name)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("ImportCSV `%v`: %w", nsm.name, err)
+		}
+
+		var key KeyT
+		err = keyColumns.parse(reflect.ValueOf(&key).Elem(), record[:len(keyColumns)])
+		if err != nil {
+			return fmt.Errorf("ImportCSV `%v`: %w", nsm.name, err)
+		}
+		var value ValueT
+		err = valueColumns.parse(reflect.ValueOf(&value).Elem(), record[len(keyColumns):])
+		if err != nil {
+			return fmt.Errorf("ImportCSV `%v`: %w", nsm.name, err)
+		}
+
+		err = nsm.Set(key, value)
+		if err != nil {
+			return fmt.Errorf("ImportCSV: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type csvColumn struct {
+	name string
+	// Index of struct field, or nil if column holds the whole value
+	fieldIndex []int
+}
+
+type csvColumnList []csvColumn
+
+func csvColumns(t reflect.Type, name string) (csvColumnList, error) {
+	if t.Kind() != reflect.Struct {
+		if !isCSVKind(t.Kind()) {
+			return nil, fmt.Errorf("csvColumns: %w: %v", ErrUnsupportedCSVType, t)
+		}
+		return csvColumnList{{name: name}}, nil
+	}
+
+	var columns csvColumnList
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if !isCSVKind(field.Type.Kind()) {
+			return nil, fmt.Errorf("csvColumns: %w: field %v of type %v", ErrUnsupportedCSVType, field.Name, field.Type)
+		}
+
+		columns = append(columns, csvColumn{
+			name:       name + "." + field.Name,
+			fieldIndex: field.Index,
+		})
+	}
+
+	return columns, nil
+}
+
+func isCSVKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func (columns csvColumnList) names() []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.name)
+	}
+
+	return names
+}
+
+func (columns csvColumnList) format(v reflect.Value) []string {
+	record := make([]string, 0, len(columns))
+	for _, column := range columns {
+		fv := v
+		if column.fieldIndex != nil {
+			fv = v.FieldByIndex(column.fieldIndex)
+		}
+
+		switch fv.Kind() {
+		case reflect.String:
+			record = append(record, fv.String())
+		case reflect.Bool:
+			record = append(record, strconv.FormatBool(fv.Bool()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			record = append(record, strconv.FormatInt(fv.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			record = append(record, strconv.FormatUint(fv.Uint(), 10))
+		case reflect.Float32, reflect.Float64:
+			record = append(record, strconv.FormatFloat(fv.Float(), 'g', -1, fv.Type().Bits()))
+		}
+	}
+
+	return record
+}
+
+func (columns csvColumnList) parse(v reflect.Value, record []string) error {
+	for i, column := range columns {
+		fv := v
+		if column.fieldIndex != nil {
+			fv = v.FieldByIndex(column.fieldIndex)
+		}
+
+		var err error
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(record[i])
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(record[i])
+			fv.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(record[i], 10, fv.Type().Bits())
+			fv.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var n uint64
+			n, err = strconv.ParseUint(record[i], 10, fv.Type().Bits())
+			fv.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(record[i], fv.Type().Bits())
+			fv.SetFloat(f)
+		}
+		if err != nil {
+			return fmt.Errorf("parse column `%v`: %w", column.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package instorage
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Iterator over key-value pairs of NamespaceMultiple in key order, which can be
+// moved to any key during iteration. Must be closed after use.
+type Cursor[KeyT comparable, ValueT any] struct {
+	nsm *NamespaceMultiple[KeyT, ValueT]
+	it  *badger.Iterator
+}
+
+// Creates cursor positioned at the first key-value pair of this namespace.
+// Keys are ordered by their gob encoded bytes.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Cursor() (*Cursor[KeyT, ValueT], error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Prefix = nsm.keyPrefix()
+
+	c := &Cursor[KeyT, ValueT]{
+		nsm: nsm,
+		it:  nsm.txn.badgertxn.NewIterator(iteratorOptions),
+	}
+	c.it.Seek(nsm.keyPrefix())
+	c.skipExpired()
+
+	return c, nil
+}
+
+// Moves cursor to the first pair with key equal or greater than passed key
+func (c *Cursor[KeyT, ValueT]) SeekTo(key KeyT) error {
+	keyb, err := c.nsm.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("SeekTo `%v`: %w", c.nsm.name, err)
+	}
+
+	c.it.Seek(joinKey(c.nsm.keyPrefix(), keyb))
+	c.skipExpired()
+
+	return nil
+}
+
+// Moves cursor to the next pair
+func (c *Cursor[KeyT, ValueT]) Next() {
+	c.it.Next()
+	c.skipExpired()
+}
+
+// Reports whether cursor points to a pair. Key and Value must not be called
+// when cursor is not valid.
+func (c *Cursor[KeyT, ValueT]) Valid() bool {
+	return c.it.ValidForPrefix(c.nsm.keyPrefix())
+}
+
+// Returns key of the current pair
+func (c *Cursor[KeyT, ValueT]) Key() (KeyT, error) {
+	keyPtr, err := decodeGob[KeyT](c.it.Item().Key()[len(c.nsm.keyPrefix()):])
+	if err != nil {
+		var key KeyT
+		return key, fmt.Errorf("Key `%v`: %w", c.nsm.name, err)
+	}
+
+	return *keyPtr, nil
+}
+
+// Returns value of the current pair
+func (c *Cursor[KeyT, ValueT]) Value() (ValueT, error) {
+	var valuePtr *ValueT
+	err := c.it.Item().Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = c.nsm.decodeValue(valueb)
+		return err
+	})
+	if err != nil {
+		var value ValueT
+		return value, fmt.Errorf("Value `%v`: %w", c.nsm.name, err)
+	}
+
+	return *valuePtr, nil
+}
+
+// Releases cursor resources. Must be called before transaction ends.
+func (c *Cursor[KeyT, ValueT]) Close() {
+	c.it.Close()
+}
+
+func (c *Cursor[KeyT, ValueT]) skipExpired() {
+	for c.Valid() && c.nsm.txn.isExpired(c.it.Item()) {
+		c.it.Next()
+	}
+}
@@ -0,0 +1,124 @@
+package instorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Summary of database returned by DB.Describe. It may be marshaled to JSON.
+type DBInfo struct {
+	// Namespaces sorted by name. Namespaces of tenants, created with
+	// Txn.Tenant, are not listed.
+	Namespaces []NamespaceInfo
+	// Size of LSM tree files in bytes
+	LSMSize int64
+	// Size of value log files in bytes
+	ValueLogSize int64
+	// Version of storage format database is written with
+	FormatVersion uint64
+	// Number of versions of each key kept by badger
+	NumVersionsToKeep int
+	ReadOnly          bool
+	InMemory          bool
+}
+
+// Summary of a single namespace
+type NamespaceInfo struct {
+	Name string
+	// Number of stored keys, not expired. For NamespaceSingle it is 1.
+	Keys int
+	// Approximate size of stored keys and values in bytes
+	Size int64
+}
+
+// Returns summary of database, which scans keys of all namespaces, so it may
+// take a while on big databases
+func (db *DB[TxnAPIT]) Describe() (DBInfo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	badgeropts := db.badgerdb.Opts()
+	info := DBInfo{
+		FormatVersion:     FormatVersion,
+		NumVersionsToKeep: badgeropts.NumVersionsToKeep,
+		ReadOnly:          badgeropts.ReadOnly,
+		InMemory:          badgeropts.InMemory,
+	}
+	info.LSMSize, info.ValueLogSize = db.badgerdb.Size()
+
+	internedNames := map[string]string{}
+	for name, prefix := range db.state.namespaceIDs {
+		internedNames[string(prefix)] = name
+	}
+
+	namespaces := map[string]*NamespaceInfo{}
+	err := db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		txn := db.newTxn(badgertxn)
+
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.PrefetchValues = false
+		it := badgertxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if txn.isExpired(item) {
+				continue
+			}
+
+			name, ok := describedNamespaceName(item.Key(), internedNames)
+			if !ok {
+				continue
+			}
+
+			nsinfo, ok := namespaces[name]
+			if !ok {
+				nsinfo = &NamespaceInfo{Name: name}
+				namespaces[name] = nsinfo
+			}
+			nsinfo.Keys++
+			nsinfo.Size += item.EstimatedSize()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return info, fmt.Errorf("Describe: %w", err)
+	}
+
+	for _, nsinfo := range namespaces {
+		info.Namespaces = append(info.Namespaces, *nsinfo)
+	}
+	sort.Slice(info.Namespaces, func(i, j int) bool {
+		return info.Namespaces[i].Name < info.Namespaces[j].Name
+	})
+
+	return info, nil
+}
+
+// Returns name of namespace key belongs to. Returns ok == false for reserved
+// and tenant keys.
+func describedNamespaceName(key []byte, internedNames map[string]string) (name string, ok bool) {
+	if bytes.HasPrefix(key, internedPrefix) {
+		prefixLen := len(internedPrefix) + 4
+		if len(key) < prefixLen {
+			return "", false
+		}
+		name, ok = internedNames[string(key[:prefixLen])]
+		return name, ok
+	}
+	if len(key) > 0 && key[0] == 0x00 {
+		return "", false
+	}
+
+	nameLen, n := binary.Uvarint(key)
+	if n <= 0 || uint64(len(key)-n) < nameLen {
+		return "", false
+	}
+
+	return string(key[n : n+int(nameLen)]), true
+}
@@ -0,0 +1,184 @@
+package instorage
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Sets value under a key of namespace values
+func setTestValue(t *testing.T, db *DB[Txn], key string, value int) {
+	t.Helper()
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[string, int](txn, "values").Set(key, value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks that key of namespace values stores passed value
+func checkTestValue(t *testing.T, db *DB[Txn], key string, want int) {
+	t.Helper()
+
+	err := db.View(func(txn Txn) error {
+		value, ok, err := NewNamespaceMultiple[string, int](txn, "values").Get(key)
+		if err != nil {
+			return err
+		}
+		if !ok || value != want {
+			t.Errorf("Get(%q) = %v, %v, expected %v", key, value, ok, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Closes db and opens database in dir again with passed options
+func reopenTestDB(t *testing.T, db *DB[Txn], dir string, opts ...Option) *DB[Txn] {
+	t.Helper()
+
+	err := db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(dir, func(txn Txn) Txn { return txn }, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
+
+func TestDropAllKeepsFormatVersion(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, func(txn Txn) Txn { return txn })
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestValue(t, db, "dropped", 1)
+
+	err = db.DropAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestValue(t, db, "a", 2)
+
+	db = reopenTestDB(t, db, dir)
+	checkTestValue(t, db, "a", 2)
+}
+
+func TestLoadBackupKeepsFormatVersion(t *testing.T) {
+	source := openTestDB(t)
+	setTestValue(t, source, "a", 1)
+
+	var backup bytes.Buffer
+	err := source.Backup(&backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	db, err := Open(dir, func(txn Txn) Txn { return txn })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.LoadBackup(&backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestValue(t, db, "b", 2)
+
+	db = reopenTestDB(t, db, dir)
+	checkTestValue(t, db, "a", 1)
+	checkTestValue(t, db, "b", 2)
+}
+
+// Sets value of namespace with passed name to its name
+func setNamespaceNames(t *testing.T, db *DB[Txn], names ...string) {
+	t.Helper()
+
+	err := db.Update(func(txn Txn) error {
+		for _, name := range names {
+			err := NewNamespaceMultiple[string, string](txn, name).Set("name", name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks that value of namespace with passed name is its name
+func checkNamespaceNames(t *testing.T, db *DB[Txn], names ...string) {
+	t.Helper()
+
+	err := db.View(func(txn Txn) error {
+		for _, name := range names {
+			value, ok, err := NewNamespaceMultiple[string, string](txn, name).Get("name")
+			if err != nil {
+				return err
+			}
+			if !ok || value != name {
+				t.Errorf("namespace `%v` stores %q, %v, expected its name", name, value, ok)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDropAllKeepsInternedNamespaceIDs(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, func(txn Txn) Txn { return txn }, WithInternedNamespaces("a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.DropAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setNamespaceNames(t, db, "a", "b")
+
+	// Names not registered would get IDs in this order
+	db = reopenTestDB(t, db, dir, WithInternedNamespaces("b", "a"))
+	checkNamespaceNames(t, db, "a", "b")
+}
+
+func TestLoadBackupReadsInternedNamespaceIDs(t *testing.T) {
+	source := openTestDB(t, WithInternedNamespaces("a", "b"))
+	setNamespaceNames(t, source, "a", "b")
+
+	var backup bytes.Buffer
+	err := source.Backup(&backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	db, err := Open(dir, func(txn Txn) Txn { return txn }, WithInternedNamespaces("b", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.LoadBackup(&backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNamespaceNames(t, db, "a", "b")
+	setNamespaceNames(t, db, "a", "b")
+
+	db = reopenTestDB(t, db, dir, WithInternedNamespaces("b", "a"))
+	checkNamespaceNames(t, db, "a", "b")
+}
@@ -0,0 +1,107 @@
+package instorage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestDropNamespaceDropsReservedKeys(t *testing.T) {
+	db := openTestDB(t, WithExpiryCallback(time.Hour, func(namespace string, keyb []byte) {}))
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, string](txn, "dropped").WithRevision().WithCollisionCheck()
+		err := nsm.Set("a", "a")
+		if err != nil {
+			return err
+		}
+		err = nsm.SetWithTTL("b", "b", time.Hour)
+		if err != nil {
+			return err
+		}
+		_, err = nsm.AppendIdempotent("request", "c", "c")
+		if err != nil {
+			return err
+		}
+		return NewIndexedNamespace[string, string](txn, "dropped_indexed", func(value string) []byte {
+			return []byte(value)
+		}).Set("a", "a")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Namespace, whose encoded name differs only by length, must be kept
+	err = db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[string, string](txn, "dropped_kept").WithRevision().SetWithTTL("a", "a", time.Hour)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dropped", "dropped_indexed"} {
+		err = db.DropNamespace(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, string](txn, "dropped").WithRevision()
+		revision, err := nsm.Revision()
+		if err != nil {
+			return err
+		}
+		if revision != 0 {
+			t.Errorf("revision is %v after drop, expected 0", revision)
+		}
+		applied, err := nsm.AppendIdempotent("request", "c", "c")
+		if err != nil {
+			return err
+		}
+		if !applied {
+			t.Error("idempotency key is still used after drop")
+		}
+
+		revision, err = NewNamespaceMultiple[string, string](txn, "dropped_kept").Revision()
+		if err != nil {
+			return err
+		}
+		if revision != 1 {
+			t.Errorf("revision of kept namespace is %v, expected 1", revision)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		it := txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for _, prefix := range [][]byte{indexPrefix("dropped_indexed"), originalKeysPrefix("dropped")} {
+			it.Seek(prefix)
+			if it.ValidForPrefix(prefix) {
+				t.Errorf("key %q is left after drop", it.Item().Key())
+			}
+		}
+
+		expiring := 0
+		for it.Seek(expiryIndexPrefix); it.ValidForPrefix(expiryIndexPrefix); it.Next() {
+			storedKey := it.Item().Key()[len(expiryIndexPrefix)+8:]
+			if bytes.HasPrefix(storedKey, db.state.namespacePrefix("dropped")) {
+				t.Errorf("expiry index entry of %q is left after drop", storedKey)
+			}
+			expiring++
+		}
+		if expiring != 1 {
+			t.Errorf("%v expiry index entries are left, expected 1 of kept namespace", expiring)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+package instorage
+
+import (
+	"context"
+	"errors"
+)
+
+// Returned by Set when encoded value exceeds limit set by WithMaxValueSize
+var ErrValueTooLarge = errors.New("value is too large")
+
+// Returned when stored value was decoded without reading all of its bytes,
+// which indicates corrupted data
+var ErrTrailingData = errors.New("trailing data after decoded value")
+
+// Returned by Open when database was written with newer FormatVersion than
+// supported by this package, or with older one and WithRewriteKeys is not
+// passed
+var ErrIncompatibleFormat = errors.New("incompatible database format")
+
+// Returned by RenameNamespace when namespace with new name already contains
+// data
+var ErrNamespaceNotEmpty = errors.New("namespace is not empty")
+
+// Returned by NamespaceVersionedSingle.Rollback when passed version is not
+// stored
+var ErrVersionNotFound = errors.New("version not found")
+
+// Returned by IndexedNamespace.Set with WithUniqueValues when another key
+// already has value with the same index bytes
+var ErrDuplicateValue = errors.New("duplicate value")
+
+// Returned by writes of namespace with WithWriteRateLimit, when its rate limit
+// is exceeded
+var ErrRateLimited = errors.New("write rate limit exceeded")
+
+// Returned by reads and writes of NamespaceMultiple, when namespace stores
+// values incompatible with its ValueT, for example after type of value struct
+// field was changed. See WithoutSchemaCheck.
+var ErrSchemaDrift = errors.New("schema drift")
+
+// Returned by collecting methods of NamespaceMultiple with WithMaxResults,
+// when result would contain more pairs than limit
+var ErrResultTooLarge = errors.New("result is too large")
+
+// Returned by writes of namespace with WithCollisionCheck, when encoded key is
+// already used by a different key
+var ErrKeyCollision = errors.New("key encoding collision")
+
+// Returned by SetIfVersion when stored version of value differs from expected
+// one
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// Returned by transactions of DB, after Reopen failed to open database both
+// with new and previous options
+var ErrDBUnusable = errors.New("database is unusable after failed Reopen")
+
+// Returned when transaction runs longer than its timeout. Matches
+// context.DeadlineExceeded with errors.Is.
+var ErrTxnTimeout error = txnTimeoutError{}
+
+type txnTimeoutError struct{}
+
+func (txnTimeoutError) Error() string {
+	return "transaction timeout exceeded"
+}
+
+func (txnTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
@@ -0,0 +1,160 @@
+package instorage
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Order, in which entries are evicted by WithMaxSize
+type EvictionPolicy int
+
+const (
+	// Entries written earliest are evicted first
+	EvictOldest EvictionPolicy = iota
+	// Entries, which were not read or written for the longest time, are
+	// evicted first. Reads are tracked in memory, so after Open entries are
+	// ordered by time they were written until they are read.
+	EvictLeastRecentlyUsed
+)
+
+// Limits total size of stored keys and values to maxSize bytes. When writes
+// made with NamespaceMultiple and NamespaceSingle exceed it, entries are
+// evicted according to policy after the transaction is committed, until size
+// is reduced to 90% of maxSize. Any entries of any namespaces may be evicted,
+// so it is meant for databases used as a cache. Size is computed from sizes of
+// entries, disk space is freed later by garbage collection.
+func WithMaxSize(maxSize int64, policy EvictionPolicy) Option {
+	if maxSize <= 0 {
+		panic("maxSize must be positive")
+	}
+
+	return func(dbopts *dbOptions) {
+		dbopts.maxSize = maxSize
+		dbopts.evictionPolicy = policy
+	}
+}
+
+// Tracks writes and reads for eviction, not used without WithMaxSize
+type evictionState struct {
+	// Estimated size of stored entries. Writes are added without accounting
+	// overwrites and deletions, it is recomputed when exceeds limit.
+	size int64
+	// Read version of the last transaction, which read key, by stored key
+	accessed sync.Map
+	// Prevents concurrent evictions
+	mu sync.Mutex
+}
+
+// Records write of entry with passed sizes
+func (txn Txn) trackWrite(keySize int, valueSize int) {
+	if txn.dbopts == nil || txn.dbopts.maxSize == 0 {
+		return
+	}
+
+	atomic.AddInt64(&txn.state.eviction.size, int64(keySize+valueSize))
+}
+
+// Records read of stored key
+func (txn Txn) trackAccess(key []byte) {
+	if txn.dbopts == nil || txn.dbopts.maxSize == 0 || txn.dbopts.evictionPolicy != EvictLeastRecentlyUsed {
+		return
+	}
+
+	txn.state.eviction.accessed.Store(string(key), txn.ReadVersion())
+}
+
+// Evicts entries, if estimated size exceeds limit set by WithMaxSize
+func (db *DB[TxnAPIT]) evictIfNeeded() error {
+	if db.dbopts.maxSize == 0 || atomic.LoadInt64(&db.state.eviction.size) <= db.dbopts.maxSize {
+		return nil
+	}
+
+	return evict(db.badgerdb, db.dbopts, &db.state.eviction)
+}
+
+type evictionCandidate struct {
+	key []byte
+	// Version of the last access, entries with smaller ones are evicted first
+	stamp uint64
+	size  int64
+}
+
+// Computes actual size of stored entries and evicts them if it exceeds limit
+func evict(badgerdb *badger.DB, dbopts *dbOptions, eviction *evictionState) error {
+	if !eviction.mu.TryLock() {
+		return nil
+	}
+	defer eviction.mu.Unlock()
+
+	var candidates []evictionCandidate
+	var size int64
+
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.PrefetchValues = false
+		it := badgertxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if bytes.HasPrefix(item.Key(), reservedPrefix) {
+				continue
+			}
+
+			candidate := evictionCandidate{
+				key:   item.KeyCopy(nil),
+				stamp: item.Version(),
+				size:  item.EstimatedSize(),
+			}
+			if dbopts.evictionPolicy == EvictLeastRecentlyUsed {
+				if stamp, ok := eviction.accessed.Load(string(candidate.key)); ok && stamp.(uint64) > candidate.stamp {
+					candidate.stamp = stamp.(uint64)
+				}
+			}
+
+			candidates = append(candidates, candidate)
+			size += candidate.size
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if size > dbopts.maxSize {
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].stamp < candidates[j].stamp
+		})
+
+		wb := badgerdb.NewWriteBatch()
+		defer wb.Cancel()
+
+		target := dbopts.maxSize / 10 * 9
+		for _, candidate := range candidates {
+			if size <= target {
+				break
+			}
+
+			err = wb.Delete(candidate.key)
+			if err != nil {
+				return err
+			}
+			eviction.accessed.Delete(string(candidate.key))
+			size -= candidate.size
+		}
+
+		err = wb.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	atomic.StoreInt64(&eviction.size, size)
+
+	return nil
+}
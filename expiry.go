@@ -0,0 +1,297 @@
+package instorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/nickname76/repeater"
+)
+
+// Calls onExpire for every key-value pair of NamespaceMultiple, which expires
+// after being set with SetWithExpiry, and deletes it. Expired pairs are checked
+// every interval, so callback is called up to interval after expiration.
+// Callback receives namespace name and gob encoded key, which may be decoded
+// with DecodeKey. Callback is called within transaction deleting the pair, if
+// it fails, for example on conflict with concurrent write, callback is called
+// again on the next check, so it may be called more than once for the same
+// pair.
+func WithExpiryCallback(interval time.Duration, onExpire func(namespace string, keyb []byte)) Option {
+	if interval <= 0 {
+		panic("interval must be positive")
+	}
+	if onExpire == nil {
+		panic("onExpire must not be nil")
+	}
+
+	return func(dbopts *dbOptions) {
+		dbopts.expiryInterval = interval
+		dbopts.onExpire = onExpire
+	}
+}
+
+// Decodes key passed to callback of WithExpiryCallback
+func DecodeKey[KeyT any](keyb []byte) (KeyT, error) {
+	keyPtr, err := decodeGob[KeyT](keyb)
+	if err != nil {
+		var key KeyT
+		return key, fmt.Errorf("DecodeKey: %w", err)
+	}
+
+	return *keyPtr, nil
+}
+
+// Index of expiring keys, ordered by expiration time. Index keys consist of
+// this prefix, 8 bytes of expiration unix time and stored key. Index values
+// consist of 4 bytes of encoded key offset in stored key and namespace name.
+var expiryIndexPrefix = addPrefixToKey(reservedKey("expiry"), nil)
+
+func expiryIndexKey(expiresAt uint64, storedKey []byte) []byte {
+	key := make([]byte, 0, len(expiryIndexPrefix)+8+len(storedKey))
+	key = append(key, expiryIndexPrefix...)
+	key = append(key, uint64Bytes(expiresAt)...)
+	return append(key, storedKey...)
+}
+
+func (txn Txn) tracksExpiry() bool {
+	return txn.dbopts != nil && txn.dbopts.onExpire != nil
+}
+
+// Removes index entry of stored key, if it was set with expiration
+func (txn Txn) untrackExpiry(storedKey []byte) error {
+	if !txn.tracksExpiry() {
+		return nil
+	}
+
+	item, err := txn.badgertxn.Get(storedKey)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		return err
+	}
+	if item.ExpiresAt() == 0 {
+		return nil
+	}
+
+	return txn.badgertxn.Delete(expiryIndexKey(item.ExpiresAt(), storedKey))
+}
+
+// Adds index entry for stored key expiring at passed unix time. Encoded key
+// starts in stored key at keyOffset.
+func (txn Txn) trackExpiry(namespace string, storedKey []byte, keyOffset int, expiresAt uint64) error {
+	if !txn.tracksExpiry() {
+		return nil
+	}
+
+	indexb := append(uint32Bytes(uint32(keyOffset)), namespace...)
+	return txn.badgertxn.Set(expiryIndexKey(expiresAt, storedKey), indexb)
+}
+
+// Starts repeater calling callback of WithExpiryCallback, if it is set
+func (handle *dbHandle) startExpiryRepeater() func() {
+	if handle.dbopts.onExpire == nil || handle.dbopts.badgerOptions.ReadOnly {
+		return func() {}
+	}
+
+	return repeater.StartRepeater(handle.dbopts.expiryInterval, func() {
+		// Skipped while Close or Reopen holds the lock, so Close does not wait
+		// for this repeater while stopping it
+		if !handle.mu.TryRLock() {
+			return
+		}
+		defer handle.mu.RUnlock()
+
+		handle.processExpired()
+	})
+}
+
+// Deletes index entries of stored keys starting with any of passed prefixes.
+// Index is ordered by expiration time, so it is scanned entirely.
+func untrackExpiryByPrefix(badgerdb *badger.DB, prefixes [][]byte) error {
+	wb := badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.PrefetchValues = false
+		it := badgertxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		for it.Seek(expiryIndexPrefix); it.ValidForPrefix(expiryIndexPrefix); it.Next() {
+			indexKey := it.Item().Key()
+			storedKey := indexKey[len(expiryIndexPrefix)+8:]
+			for _, prefix := range prefixes {
+				if !bytes.HasPrefix(storedKey, prefix) {
+					continue
+				}
+				err := wb.Delete(it.Item().KeyCopy(nil))
+				if err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return wb.Flush()
+}
+
+// Adds index entries for stored keys starting with oldPrefix, as if they were
+// moved or copied under newPrefix of namespace newName. Entries of old keys are
+// deleted, if move is true.
+func reindexExpiry(badgerdb *badger.DB, oldPrefix []byte, newPrefix []byte, newName string, move bool) error {
+	wb := badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(expiryIndexPrefix); it.ValidForPrefix(expiryIndexPrefix); it.Next() {
+			indexKey := it.Item().Key()
+			storedKey := indexKey[len(expiryIndexPrefix)+8:]
+			if !bytes.HasPrefix(storedKey, oldPrefix) {
+				continue
+			}
+
+			indexb, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if len(indexb) < 4 {
+				return errors.New("malformed expiry index entry")
+			}
+
+			expiresAt := binary.BigEndian.Uint64(indexKey[len(expiryIndexPrefix):])
+			keyOffset := int(binary.BigEndian.Uint32(indexb)) + len(newPrefix) - len(oldPrefix)
+			newStoredKey := joinKey(newPrefix, storedKey[len(oldPrefix):])
+
+			err = wb.Set(expiryIndexKey(expiresAt, newStoredKey), append(uint32Bytes(uint32(keyOffset)), newName...))
+			if err != nil {
+				return err
+			}
+			if move {
+				err = wb.Delete(it.Item().KeyCopy(nil))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reindexExpiry: %w", err)
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return fmt.Errorf("reindexExpiry: %w", err)
+	}
+
+	return nil
+}
+
+// Maximal number of expired entries processed in one transaction
+const expiredBatchSize = 1000
+
+// Calls callback for expired entries and deletes them with their index entries
+// in the same transaction, so entries changed concurrently are not reported
+func (handle *dbHandle) processExpired() error {
+	now := uint64(handle.dbopts.clock().Unix())
+
+	for {
+		processed := 0
+		err := handle.badgerdb.Update(func(badgertxn *badger.Txn) error {
+			it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+
+			var indexKeys [][]byte
+			for it.Seek(expiryIndexPrefix); it.ValidForPrefix(expiryIndexPrefix) && len(indexKeys) < expiredBatchSize; it.Next() {
+				indexKey := it.Item().KeyCopy(nil)
+
+				expiresAt := binary.BigEndian.Uint64(indexKey[len(expiryIndexPrefix):])
+				if expiresAt > now {
+					break
+				}
+
+				indexb, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				indexKeys = append(indexKeys, indexKey)
+
+				// Key set again after expiration, but before it was processed
+				storedKey := indexKey[len(expiryIndexPrefix)+8:]
+				stale, err := isStaleExpiry(badgertxn, storedKey, expiresAt)
+				if err != nil {
+					return err
+				}
+				if stale {
+					continue
+				}
+
+				keyOffset := binary.BigEndian.Uint32(indexb)
+				handle.dbopts.onExpire(string(indexb[4:]), storedKey[keyOffset:])
+			}
+
+			for _, indexKey := range indexKeys {
+				err := badgertxn.Delete(indexKey)
+				if err != nil {
+					return err
+				}
+
+				storedKey := indexKey[len(expiryIndexPrefix)+8:]
+				expiresAt := binary.BigEndian.Uint64(indexKey[len(expiryIndexPrefix):])
+				stale, err := isStaleExpiry(badgertxn, storedKey, expiresAt)
+				if err != nil {
+					return err
+				}
+				if stale {
+					continue
+				}
+
+				err = badgertxn.Delete(storedKey)
+				if err != nil {
+					return err
+				}
+			}
+			processed = len(indexKeys)
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("processExpired: %w", err)
+		}
+
+		if processed < expiredBatchSize {
+			return nil
+		}
+	}
+}
+
+// Reports whether stored key exists with expiration different from indexed
+// one. Badger does not return keys expired by real time, so they are never
+// stale.
+func isStaleExpiry(badgertxn *badger.Txn, storedKey []byte, expiresAt uint64) (bool, error) {
+	item, err := badgertxn.Get(storedKey)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return item.ExpiresAt() != expiresAt, nil
+}
@@ -0,0 +1,124 @@
+package instorage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+var exportFileHeader = []byte("instorage export\x00")
+
+// Returned by OpenFromFile when file was not written by ExportToFile
+var ErrNotExportFile = errors.New("not an instorage export file")
+
+// Writes whole database into a single compressed file at path, which can be
+// opened with OpenFromFile.
+func (db *DB[TxnAPIT]) ExportToFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ExportToFile: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	_, err = w.Write(exportFileHeader)
+	if err != nil {
+		return fmt.Errorf("ExportToFile: %w", err)
+	}
+
+	gzw := gzip.NewWriter(w)
+
+	err = db.Backup(gzw)
+	if err != nil {
+		return fmt.Errorf("ExportToFile: %w", err)
+	}
+
+	err = gzw.Close()
+	if err != nil {
+		return fmt.Errorf("ExportToFile: %w", err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("ExportToFile: %w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("ExportToFile: %w", err)
+	}
+
+	return nil
+}
+
+// Opens database from file written by ExportToFile in read-only mode. File is
+// restored into a temporary directory, which is removed on Close.
+func OpenFromFile[TxnAPIT any](path string, txnAPIBuilder func(txn Txn) TxnAPIT, opts ...Option) (*DB[TxnAPIT], error) {
+	tempDir, err := os.MkdirTemp("", "instorage-")
+	if err != nil {
+		return nil, fmt.Errorf("OpenFromFile: %w", err)
+	}
+
+	err = restoreExportFile(path, tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("OpenFromFile: %w", err)
+	}
+
+	opts = append(opts[:len(opts):len(opts)], withReadOnly())
+
+	db, err := Open(tempDir, txnAPIBuilder, opts...)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("OpenFromFile: %w", err)
+	}
+	db.tempDir = tempDir
+
+	return db, nil
+}
+
+func restoreExportFile(path string, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("restoreExportFile: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	header := make([]byte, len(exportFileHeader))
+	_, err = io.ReadFull(r, header)
+	if err != nil || !bytes.Equal(header, exportFileHeader) {
+		return fmt.Errorf("restoreExportFile: %w", ErrNotExportFile)
+	}
+
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("restoreExportFile: %w", err)
+	}
+
+	badgerdb, err := badger.Open(badger.DefaultOptions(dir).WithLoggingLevel(badger.ERROR))
+	if err != nil {
+		return fmt.Errorf("restoreExportFile: %w", err)
+	}
+
+	err = badgerdb.Load(gzr, 64)
+	if err != nil {
+		badgerdb.Close()
+		return fmt.Errorf("restoreExportFile: %w", err)
+	}
+
+	err = badgerdb.Close()
+	if err != nil {
+		return fmt.Errorf("restoreExportFile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package instorage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Returned by ExportGoLiteral for keys and values, which %#v formatting does
+// not render as compilable Go source
+var ErrUnsupportedGoLiteralType = errors.New("type is not supported in Go literal")
+
+// Writes all key-value pairs as Go source declaring variable varName with
+// map[KeyT]ValueT literal, for example to capture test fixtures. Pairs are
+// formatted with %#v, so keys and values must be of basic types, arrays,
+// slices, maps and structs with only exported fields of such types. Pointers,
+// interfaces, channels and functions are rejected with
+// ErrUnsupportedGoLiteralType.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ExportGoLiteral(w io.Writer, varName string) error {
+	mapType := reflect.TypeOf((map[KeyT]ValueT)(nil))
+
+	err := checkGoLiteralType(mapType)
+	if err != nil {
+		return fmt.Errorf("ExportGoLiteral `%v`: %w", nsm.name, err)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "var %s = %v{\n", varName, mapType)
+	err = nsm.Iter(func(key KeyT, value ValueT) (bool, error) {
+		_, err := fmt.Fprintf(bw, "\t%#v: %#v,\n", key, value)
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("ExportGoLiteral: %w", err)
+	}
+	fmt.Fprint(bw, "}\n")
+
+	err = bw.Flush()
+	if err != nil {
+		return fmt.Errorf("ExportGoLiteral `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+func checkGoLiteralType(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return nil
+	case reflect.Array, reflect.Slice:
+		return checkGoLiteralType(t.Elem())
+	case reflect.Map:
+		err := checkGoLiteralType(t.Key())
+		if err != nil {
+			return err
+		}
+		return checkGoLiteralType(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				return fmt.Errorf("%w: %v has unexported field %v", ErrUnsupportedGoLiteralType, t, field.Name)
+			}
+
+			err := checkGoLiteralType(field.Type)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedGoLiteralType, t)
+	}
+}
@@ -0,0 +1,43 @@
+package instorage
+
+import "fmt"
+
+// Writes key-value pairs received from in into NamespaceMultiple with passed
+// name until in is closed, batching writes with badger WriteBatch. Pairs are
+// not written atomically, on error remaining pairs are not read from in.
+// Values are encoded with gob. Returns number of written pairs.
+func IngestChannel[TxnAPIT any, KeyT comparable, ValueT any](db *DB[TxnAPIT], namespace string, in <-chan KeyValue[KeyT, ValueT]) (int, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefix := db.state.namespacePrefix(namespace)
+
+	wb := db.badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	count := 0
+	for kv := range in {
+		keyb, err := encodeGob(kv.Key)
+		if err != nil {
+			return count, fmt.Errorf("IngestChannel `%v`: %w", namespace, err)
+		}
+		valueb, err := encodeGob(kv.Value)
+		if err != nil {
+			return count, fmt.Errorf("IngestChannel `%v`: %w", namespace, err)
+		}
+
+		err = wb.Set(joinKey(prefix, keyb), valueb)
+		if err != nil {
+			return count, fmt.Errorf("IngestChannel `%v`: %w", namespace, err)
+		}
+
+		count++
+	}
+
+	err := wb.Flush()
+	if err != nil {
+		return count, fmt.Errorf("IngestChannel `%v`: %w", namespace, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,65 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestInitOnceConflictsWithDifferentSeeds(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		seeded, err := NewNamespaceMultiple[string, int](txn, "seeded").InitOnce(map[string]int{"a": 1})
+		if err != nil {
+			return err
+		}
+		if !seeded {
+			t.Error("empty namespace is not seeded")
+		}
+
+		// Concurrent seeder with different keys commits first
+		return db.Update(func(txn Txn) error {
+			_, err := NewNamespaceMultiple[string, int](txn, "seeded").InitOnce(map[string]int{"b": 2})
+			return err
+		})
+	})
+	if !errors.Is(err, badger.ErrConflict) {
+		t.Fatalf("concurrent seeding returned %v, expected badger.ErrConflict", err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, ok, err := NewNamespaceMultiple[string, int](txn, "seeded").Get("a")
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.Error("seed of conflicting transaction is committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitOnceSkipsNotEmptyNamespace(t *testing.T) {
+	db := openTestDB(t)
+
+	for i, want := range []bool{true, false} {
+		err := db.Update(func(txn Txn) error {
+			seeded, err := NewNamespaceMultiple[string, int](txn, "seeded").InitOnce(map[string]int{"a": i})
+			if err != nil {
+				return err
+			}
+			if seeded != want {
+				t.Errorf("seeding %v reported %v, expected %v", i, seeded, want)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package instorage
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
@@ -11,50 +14,291 @@ import (
 
 // Database api object
 type DB[TxnAPIT any] struct {
+	*dbHandle
+	txnAPIBuilder func(txn Txn) TxnAPIT
+	// Set by Close, guarded by mu
+	closed bool
+}
+
+// Opened database shared by DB and all of its facades created with WithTxnAPI
+type dbHandle struct {
+	// Guards badgerdb from being swapped by Reopen while in use
+	mu             sync.RWMutex
 	badgerdb       *badger.DB
 	stopGCRepeater func()
-	txnAPIBuilder  func(txn Txn) TxnAPIT
+	// Stops repeater of WithExpiryCallback
+	stopExpiryRepeater func()
+	dbopts             *dbOptions
+	state              *dbState
+	// Directory removed on Close, used by OpenFromFile
+	tempDir string
+	// Number of not closed DB objects using this handle, guarded by mu
+	refs int
+	// Value log GC counters kept across Reopen
+	gc *gcCounters
+	// Repeaters of tasks added by Schedule, by IDs
+	tasks *repeater.MultiRepeater[uint64]
+	// ID of the last task added by Schedule, guarded by mu
+	lastTaskID uint64
+	// Database was not closed properly last time, reported by WasRecovered
+	recovered bool
+	// Set when Reopen failed to open database again, guarded by mu. Badger
+	// database is closed then.
+	unusable error
 }
 
 // Opens database from dbpath and stores txnAPIBuilder for building TxnAPI in
-// View and Update methods of DB
-func Open[TxnAPIT any](dbpath string, txnAPIBuilder func(txn Txn) TxnAPIT) (*DB[TxnAPIT], error) {
+// View and Update methods of DB. Pass options to tune database behaviour.
+func Open[TxnAPIT any](dbpath string, txnAPIBuilder func(txn Txn) TxnAPIT, opts ...Option) (*DB[TxnAPIT], error) {
 	if txnAPIBuilder == nil {
 		panic("txnAPIBuilder must not be nil")
 	}
 
-	badgerdb, err := badger.Open(badger.DefaultOptions(dbpath).WithLoggingLevel(badger.ERROR))
+	dbopts := newDBOptions(dbpath, opts)
+
+	gc := &gcCounters{}
+	state := &dbState{
+		gets: &getCounters{},
+	}
+
+	badgerdb, stopGCRepeater, err := openBadger(dbopts.badgerOptions, dbopts, gc)
 	if err != nil {
 		return nil, fmt.Errorf("Open: %w", err)
 	}
 
-	badgerdb.RunValueLogGC(0.1)
+	state.namespaceIDs, err = internNamespaces(badgerdb, dbopts.internedNames)
+	if err != nil {
+		stopGCRepeater()
+		badgerdb.Close()
+		return nil, fmt.Errorf("Open: %w", err)
+	}
 
-	err = badgerdb.Flatten(16)
+	recovered, err := markOpen(badgerdb)
 	if err != nil {
+		stopGCRepeater()
+		badgerdb.Close()
 		return nil, fmt.Errorf("Open: %w", err)
 	}
 
-	stopGCRepeater := repeater.StartRepeater(time.Minute, func() {
-		badgerdb.RunValueLogGC(0.5)
-	})
+	if dbopts.maxSize > 0 && !dbopts.badgerOptions.ReadOnly {
+		err = evict(badgerdb, dbopts, &state.eviction)
+		if err != nil {
+			stopGCRepeater()
+			badgerdb.Close()
+			return nil, fmt.Errorf("Open: %w", err)
+		}
+	}
 
-	return &DB[TxnAPIT]{
+	handle := &dbHandle{
 		badgerdb:       badgerdb,
 		stopGCRepeater: stopGCRepeater,
-		txnAPIBuilder:  txnAPIBuilder,
+		dbopts:         dbopts,
+		state:          state,
+		gc:             gc,
+		refs:           1,
+		tasks:          repeater.NewMultiRepeater[uint64](),
+		recovered:      recovered,
+	}
+	handle.stopExpiryRepeater = handle.startExpiryRepeater()
+
+	return &DB[TxnAPIT]{
+		dbHandle:      handle,
+		txnAPIBuilder: txnAPIBuilder,
 	}, nil
 }
 
+// Returns DB with another TxnAPI, which shares opened database, caches and GC
+// with db, so the same directory may be used by independent parts of program
+// with their own TxnAPIs. Database is closed when Close or CloseCompact is
+// called on db and all of returned facades.
+func WithTxnAPI[NewTxnAPIT, TxnAPIT any](db *DB[TxnAPIT], txnAPIBuilder func(txn Txn) NewTxnAPIT) *DB[NewTxnAPIT] {
+	if txnAPIBuilder == nil {
+		panic("txnAPIBuilder must not be nil")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		panic("db must not be closed")
+	}
+	db.refs++
+
+	return &DB[NewTxnAPIT]{
+		dbHandle:      db.dbHandle,
+		txnAPIBuilder: txnAPIBuilder,
+	}
+}
+
+// State shared by DB and all of its transactions
+type dbState struct {
+	// First field, so its counter is 64-bit aligned for atomic operations
+	eviction evictionState
+	// Allocated separately, so its counters are 64-bit aligned too
+	gets *getCounters
+	// Token buckets of WithWriteRateLimit by namespace prefixes
+	writeLimiters sync.Map
+	// Value schemas read from schema keys of namespaces, by schema keys
+	schemas sync.Map
+	// Gob type descriptions stored by WithSharedTypeDescriptor, by their keys,
+	// and their indexes, by descriptions prefixed with their key prefixes
+	typeDescriptors       sync.Map
+	typeDescriptorIndexes sync.Map
+	// Key prefixes of namespaces interned with WithInternedNamespaces, modified
+	// after Open only by LoadBackup with mu locked
+	namespaceIDs map[string][]byte
+}
+
+func (state *dbState) namespacePrefix(name string) []byte {
+	if state != nil {
+		if prefix, ok := state.namespaceIDs[name]; ok {
+			return prefix
+		}
+	}
+
+	return addPrefixToKey(encodeNamespaceName(name), nil)
+}
+
+// Same as Open, but if database can not be opened from dbpath, for example on
+// read-only filesystem, opens empty in-memory database instead and returns
+// usedMemory == true. Data of in-memory database is lost on Close.
+func OpenWithFallback[TxnAPIT any](dbpath string, txnAPIBuilder func(txn Txn) TxnAPIT, opts ...Option) (db *DB[TxnAPIT], usedMemory bool, err error) {
+	db, err = Open(dbpath, txnAPIBuilder, opts...)
+	if err == nil {
+		return db, false, nil
+	}
+
+	opts = append(opts[:len(opts):len(opts)], withInMemory())
+
+	db, memErr := Open("", txnAPIBuilder, opts...)
+	if memErr != nil {
+		return nil, false, fmt.Errorf("OpenWithFallback: %w, in-memory fallback failed: %v", err, memErr)
+	}
+
+	return db, true, nil
+}
+
+func openBadger(badgeropts badger.Options, dbopts *dbOptions, gc *gcCounters) (badgerdb *badger.DB, stopGCRepeater func(), err error) {
+	badgerdb, err = badger.Open(badgeropts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("openBadger: %w", err)
+	}
+
+	err = checkFormatVersion(badgerdb, dbopts.rewriteKeys)
+	if err != nil {
+		badgerdb.Close()
+		return nil, nil, fmt.Errorf("openBadger: %w", err)
+	}
+
+	if badgeropts.ReadOnly {
+		return badgerdb, func() {}, nil
+	}
+
+	gc.run(badgerdb, 0.1)
+
+	err = badgerdb.Flatten(16)
+	if err != nil {
+		badgerdb.Close()
+		return nil, nil, fmt.Errorf("openBadger: %w", err)
+	}
+
+	return badgerdb, startGCRepeater(badgerdb, dbopts, gc), nil
+}
+
+// Starts repeater running value log GC of badgerdb. Returned stop function may
+// be called multiple times.
+func startGCRepeater(badgerdb *badger.DB, dbopts *dbOptions, gc *gcCounters) (stop func()) {
+	stopRepeater := repeater.StartRepeater(time.Minute, func() {
+		gc.run(badgerdb, 0.5)
+		dbopts.checkCompactionBacklog(badgerdb)
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(stopRepeater)
+	}
+}
+
+// Opens badger database like openBadger and stores open marker in it. Database
+// is closed, if marker can not be stored.
+func openMarkedBadger(badgeropts badger.Options, dbopts *dbOptions, gc *gcCounters) (badgerdb *badger.DB, stopGCRepeater func(), err error) {
+	badgerdb, stopGCRepeater, err = openBadger(badgeropts, dbopts, gc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = markOpen(badgerdb)
+	if err != nil {
+		stopGCRepeater()
+		badgerdb.Close()
+		return nil, nil, err
+	}
+
+	return badgerdb, stopGCRepeater, nil
+}
+
+// Waits all pending transactions, closes database and opens it again from the
+// same directory with newOpts. Existing references to DB remain valid. If
+// database can not be opened with newOpts, it is opened again with previous
+// options and error is returned. If that fails too, transactions of DB return
+// ErrDBUnusable until it is closed.
+func (db *DB[TxnAPIT]) Reopen(newOpts badger.Options) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.unusable != nil {
+		return fmt.Errorf("Reopen: %w", db.unusable)
+	}
+
+	oldOpts := db.badgerdb.Opts()
+	newOpts.Dir = oldOpts.Dir
+	newOpts.ValueDir = oldOpts.ValueDir
+
+	db.stopGCRepeater()
+
+	err := markClosed(db.badgerdb)
+	if err != nil {
+		if !oldOpts.ReadOnly {
+			db.stopGCRepeater = startGCRepeater(db.badgerdb, db.dbopts, db.gc)
+		}
+		return fmt.Errorf("Reopen: %w", err)
+	}
+	err = db.badgerdb.Close()
+	if err != nil {
+		return fmt.Errorf("Reopen: %w", db.restore(oldOpts, err))
+	}
+
+	badgerdb, stopGCRepeater, err := openMarkedBadger(newOpts, db.dbopts, db.gc)
+	if err != nil {
+		return fmt.Errorf("Reopen: %w", db.restore(oldOpts, err))
+	}
+
+	db.badgerdb = badgerdb
+	db.stopGCRepeater = stopGCRepeater
+
+	return nil
+}
+
+// Opens database again with oldOpts after Reopen failed with err, and returns
+// err. If it can not be opened, handle is marked unusable. Must be called with
+// mu locked.
+func (handle *dbHandle) restore(oldOpts badger.Options, err error) error {
+	badgerdb, stopGCRepeater, restoreErr := openMarkedBadger(oldOpts, handle.dbopts, handle.gc)
+	if restoreErr != nil {
+		handle.unusable = fmt.Errorf("%w: %v, opening with previous options: %v", ErrDBUnusable, err, restoreErr)
+		return handle.unusable
+	}
+
+	handle.badgerdb = badgerdb
+	handle.stopGCRepeater = stopGCRepeater
+
+	return err
+}
+
 // Starts read-write transaction with your TxnAPI. If error is returned during
 // transaction, all previous operations under this transaction are discarded.
 func (db *DB[TxnAPIT]) Update(updater func(txnAPI TxnAPIT) error) error {
-	err := db.badgerdb.Update(func(badgertxn *badger.Txn) error {
-		txnAPI := db.txnAPIBuilder(Txn{
-			badgertxn: badgertxn,
-		})
-		return updater(txnAPI)
-	})
+	err := db.update(context.Background(), updater)
 	if err != nil {
 		return fmt.Errorf("Update: %w", err)
 	}
@@ -64,12 +308,7 @@ func (db *DB[TxnAPIT]) Update(updater func(txnAPI TxnAPIT) error) error {
 
 // Starts read-only transaction with your TxnAPI.
 func (db *DB[TxnAPIT]) View(viewer func(txnAPI TxnAPIT) error) error {
-	err := db.badgerdb.View(func(badgertxn *badger.Txn) error {
-		txnAPI := db.txnAPIBuilder(Txn{
-			badgertxn: badgertxn,
-		})
-		return viewer(txnAPI)
-	})
+	err := db.view(context.Background(), viewer)
 	if err != nil {
 		return fmt.Errorf("View: %w", err)
 	}
@@ -77,9 +316,170 @@ func (db *DB[TxnAPIT]) View(viewer func(txnAPI TxnAPIT) error) error {
 	return nil
 }
 
-// Deletes all data in database
+// Same as Update, but transaction is discarded with ErrTxnTimeout if it runs
+// longer than timeout. Iterations over namespaces are stopped on timeout, but
+// other code in updater is not interrupted, so transaction ends only after
+// updater returns.
+func (db *DB[TxnAPIT]) UpdateWithTimeout(timeout time.Duration, updater func(txnAPI TxnAPIT) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := db.update(ctx, updater)
+	if err != nil {
+		return fmt.Errorf("UpdateWithTimeout: %w", err)
+	}
+
+	return nil
+}
+
+// Same as View, but returns ErrTxnTimeout if transaction runs longer than
+// timeout. Iterations over namespaces are stopped on timeout, but other code in
+// viewer is not interrupted, so transaction ends only after viewer returns.
+func (db *DB[TxnAPIT]) ViewWithTimeout(timeout time.Duration, viewer func(txnAPI TxnAPIT) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := db.view(ctx, viewer)
+	if err != nil {
+		return fmt.Errorf("ViewWithTimeout: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB[TxnAPIT]) update(ctx context.Context, updater func(txnAPI TxnAPIT) error) error {
+	return db.updateTxn(ctx, func(txn Txn) error {
+		return updater(db.txnAPIBuilder(txn))
+	})
+}
+
+// Same as update, but passes Txn to updater instead of TxnAPI
+func (db *DB[TxnAPIT]) updateTxn(ctx context.Context, updater func(txn Txn) error) error {
+	ctx, cancel := db.dbopts.withDefaultTimeout(ctx)
+	defer cancel()
+
+	releaseSlot, err := db.dbopts.acquireTxnSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer releaseSlot()
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.unusable != nil {
+		return db.unusable
+	}
+
+	err = db.badgerdb.Update(func(badgertxn *badger.Txn) error {
+		txn := db.newTxn(badgertxn)
+		txn.ctx = ctx
+		txn.beforeCommit = new([]func() error)
+
+		err := updater(txn)
+		if err != nil {
+			return err
+		}
+		err = txn.runBeforeCommit()
+		if err != nil {
+			return fmt.Errorf("before commit: %w", err)
+		}
+
+		return txn.checkContext()
+	})
+	if err != nil {
+		return err
+	}
+
+	err = db.evictIfNeeded()
+	if err != nil {
+		return fmt.Errorf("evicting after commit: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB[TxnAPIT]) view(ctx context.Context, viewer func(txnAPI TxnAPIT) error) error {
+	return db.viewTxn(ctx, func(txn Txn) error {
+		return viewer(db.txnAPIBuilder(txn))
+	})
+}
+
+// Same as view, but passes Txn to viewer instead of TxnAPI
+func (db *DB[TxnAPIT]) viewTxn(ctx context.Context, viewer func(txn Txn) error) error {
+	ctx, cancel := db.dbopts.withDefaultTimeout(ctx)
+	defer cancel()
+
+	releaseSlot, err := db.dbopts.acquireTxnSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer releaseSlot()
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.unusable != nil {
+		return db.unusable
+	}
+
+	return db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		txn := db.newTxn(badgertxn)
+		txn.ctx = ctx
+
+		err := viewer(txn)
+		if err != nil {
+			return err
+		}
+
+		return txn.checkContext()
+	})
+}
+
+func (db *DB[TxnAPIT]) newTxn(badgertxn *badger.Txn) Txn {
+	return Txn{
+		badgertxn: badgertxn,
+		badgerdb:  db.badgerdb,
+		dbopts:    db.dbopts,
+		state:     db.state,
+	}
+}
+
+// Returns underlying badger database. This is an escape hatch for features
+// not exposed by instorage, use at your own risk: data written through it
+// bypasses namespace encoding, and handle becomes invalid after Reopen or
+// Close.
+func (db *DB[TxnAPIT]) Badger() *badger.DB {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.badgerdb
+}
+
+// Deletes all data in database. IDs of namespaces interned with
+// WithInternedNamespaces are kept.
 func (db *DB[TxnAPIT]) DropAll() error {
-	err := db.badgerdb.DropAll()
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	registry, err := readNamespaceRegistry(db.badgerdb)
+	if err != nil {
+		return fmt.Errorf("DropAll: %w", err)
+	}
+
+	err = db.badgerdb.DropAll()
+	if err != nil {
+		return fmt.Errorf("DropAll: %w", err)
+	}
+
+	err = storeNamespaceRegistry(db.badgerdb, registry)
+	if err != nil {
+		return fmt.Errorf("DropAll: %w", err)
+	}
+	db.state.forgetSchemasIf(func(key string) bool { return true })
+	db.state.forgetTypeDescriptorsIf(func(key string) bool { return true })
+
+	err = storeFormatVersion(db.badgerdb)
 	if err != nil {
 		return fmt.Errorf("DropAll: %w", err)
 	}
@@ -87,39 +487,447 @@ func (db *DB[TxnAPIT]) DropAll() error {
 	return nil
 }
 
-// Deletes data in passed namespace from database
+// Returns prefixes of reserved keys belonging to namespace with passed name:
+// its index, schema, type descriptions, revision, idempotency markers,
+// original keys and seeding marker. Prefixes are returned in the same order
+// for every name, so keys may be moved or copied between namespaces.
+func namespaceReservedPrefixes(name string) [][]byte {
+	return [][]byte{
+		indexPrefix(name),
+		schemaKey(name),
+		typeDescriptorsPrefix(name),
+		namespaceRevisionKey(name),
+		idempotencyPrefix(name),
+		originalKeysPrefix(name),
+		initMarkerKey(name),
+	}
+}
+
+// Deletes data in passed namespace from database, with its reserved keys and
+// expiry index entries, so namespace created again with the same name starts
+// empty
 func (db *DB[TxnAPIT]) DropNamespace(name string) error {
-	err := db.badgerdb.DropPrefix([]byte(name))
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dataPrefixes := [][]byte{encodeNamespaceName(name)}
+	if prefix, ok := db.state.namespaceIDs[name]; ok {
+		dataPrefixes = append(dataPrefixes, prefix)
+	}
+
+	err := untrackExpiryByPrefix(db.badgerdb, dataPrefixes)
+	if err != nil {
+		return fmt.Errorf("DropNamespace: %w", err)
+	}
+
+	prefixes := append(dataPrefixes, namespaceReservedPrefixes(name)...)
+	err = db.badgerdb.DropPrefix(prefixes...)
 	if err != nil {
 		return fmt.Errorf("DropNamespace: %w", err)
 	}
+	db.state.schemas.Delete(string(schemaKey(name)))
+	db.state.forgetTypeDescriptors(name)
+
+	return nil
+}
+
+// Moves all data of namespace oldName to namespace newName, including its
+// reserved keys, like index of IndexedNamespace and revision, and expiry index
+// entries. Fails with ErrNamespaceNotEmpty if newName already contains data. Other transactions wait until moving is finished, so they do
+// not see partially renamed namespace. Value of NamespaceSingle with oldName is
+// not moved.
+func (db *DB[TxnAPIT]) RenameNamespace(oldName, newName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, name := range []string{oldName, newName} {
+		if name == "" {
+			return fmt.Errorf("RenameNamespace: invalid namespace name `%v`", name)
+		}
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	oldPrefix := db.state.namespacePrefix(oldName)
+	newPrefix := db.state.namespacePrefix(newName)
+
+	err := db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.PrefetchValues = false
+		it := badgertxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		for _, prefix := range [][]byte{newPrefix, indexPrefix(newName)} {
+			it.Seek(prefix)
+			if it.ValidForPrefix(prefix) {
+				return fmt.Errorf("%w: `%v`", ErrNamespaceNotEmpty, newName)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("RenameNamespace `%v` to `%v`: %w", oldName, newName, err)
+	}
+
+	err = reindexExpiry(db.badgerdb, oldPrefix, newPrefix, newName, true)
+	if err != nil {
+		return fmt.Errorf("RenameNamespace `%v` to `%v`: %w", oldName, newName, err)
+	}
+	err = moveKeys(db.badgerdb, oldPrefix, newPrefix)
+	if err != nil {
+		return fmt.Errorf("RenameNamespace `%v` to `%v`: %w", oldName, newName, err)
+	}
+
+	newReserved := namespaceReservedPrefixes(newName)
+	err = db.badgerdb.DropPrefix(newReserved...)
+	if err != nil {
+		return fmt.Errorf("RenameNamespace `%v` to `%v`: %w", oldName, newName, err)
+	}
+	for i, oldReserved := range namespaceReservedPrefixes(oldName) {
+		err = moveKeys(db.badgerdb, oldReserved, newReserved[i])
+		if err != nil {
+			return fmt.Errorf("RenameNamespace `%v` to `%v`: %w", oldName, newName, err)
+		}
+	}
+	db.state.schemas.Delete(string(schemaKey(oldName)))
+	db.state.schemas.Delete(string(schemaKey(newName)))
+	db.state.forgetTypeDescriptors(oldName)
+	db.state.forgetTypeDescriptors(newName)
+
+	return nil
+}
+
+// Replaces data of namespace dst with copy of data of namespace src, including
+// its reserved keys, like index of IndexedNamespace and revision, and expiry
+// index entries, so dst may be read while src is changed. Other
+// transactions wait until copying is finished, so they see either the previous
+// or the new copy in dst.
+func (db *DB[TxnAPIT]) SnapshotNamespace(src, dst string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, name := range []string{src, dst} {
+		if name == "" {
+			return fmt.Errorf("SnapshotNamespace: invalid namespace name `%v`", name)
+		}
+	}
+	if src == dst {
+		return nil
+	}
+
+	srcPrefix := db.state.namespacePrefix(src)
+	dstPrefix := db.state.namespacePrefix(dst)
+
+	err := untrackExpiryByPrefix(db.badgerdb, [][]byte{dstPrefix})
+	if err != nil {
+		return fmt.Errorf("SnapshotNamespace `%v` to `%v`: %w", src, dst, err)
+	}
+	dstReserved := namespaceReservedPrefixes(dst)
+	err = db.badgerdb.DropPrefix(append([][]byte{dstPrefix}, dstReserved...)...)
+	if err != nil {
+		return fmt.Errorf("SnapshotNamespace `%v` to `%v`: %w", src, dst, err)
+	}
+	db.state.schemas.Delete(string(schemaKey(dst)))
+	db.state.forgetTypeDescriptors(dst)
+
+	err = copyKeys(db.badgerdb, srcPrefix, dstPrefix)
+	if err != nil {
+		return fmt.Errorf("SnapshotNamespace `%v` to `%v`: %w", src, dst, err)
+	}
+	err = reindexExpiry(db.badgerdb, srcPrefix, dstPrefix, dst, false)
+	if err != nil {
+		return fmt.Errorf("SnapshotNamespace `%v` to `%v`: %w", src, dst, err)
+	}
+	for i, srcReserved := range namespaceReservedPrefixes(src) {
+		err = copyKeys(db.badgerdb, srcReserved, dstReserved[i])
+		if err != nil {
+			return fmt.Errorf("SnapshotNamespace `%v` to `%v`: %w", src, dst, err)
+		}
+	}
+
+	return nil
+}
+
+// Compacts storage structure after big deletions in passed namespace. Badger
+// does not support compaction of a single key range, so the whole database is
+// flattened, which may take a while on big databases.
+func (db *DB[TxnAPIT]) CompactNamespace(name string) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	err := db.badgerdb.Flatten(16)
+	if err != nil {
+		return fmt.Errorf("CompactNamespace `%v`: %w", name, err)
+	}
+
+	return nil
+}
+
+// Deletes key-value pairs of NamespaceMultiple with passed name, for which drop
+// returns true, and compacts database like CompactNamespace, so their space is
+// freed. Badger does not allow to drop entries during its garbage collection,
+// so it may be used to purge soft-deleted records in bulk. Values are decoded
+// like by NamespaceMultiple, which is configured by configure, if it is not
+// nil, so options changing encoding of values, like WithCompression, must be
+// set there. Expired pairs are skipped. Returns number of deleted pairs.
+func CompactNamespaceDropping[TxnAPIT any, KeyT comparable, ValueT any](db *DB[TxnAPIT], name string, configure func(nsm *NamespaceMultiple[KeyT, ValueT]), drop func(key KeyT, value ValueT) bool) (dropped int, err error) {
+	Txn{state: db.state}.validateNamespaceName(name)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	wb := db.badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err = db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		nsm := NewNamespaceMultiple[KeyT, ValueT](db.newTxn(badgertxn), name)
+		if configure != nil {
+			configure(nsm)
+		}
+		err := nsm.checkSchema(false)
+		if err != nil {
+			return err
+		}
+
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(nsm.prefix); it.ValidForPrefix(nsm.prefix); it.Next() {
+			item := it.Item()
+			if nsm.txn.isExpired(item) {
+				continue
+			}
+
+			keyPtr, err := decodeGob[KeyT](item.Key()[len(nsm.prefix):])
+			if err != nil {
+				return err
+			}
+
+			var valuePtr *ValueT
+			err = item.Value(func(valueb []byte) error {
+				var err error
+				valuePtr, err = nsm.decodeValue(valueb)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if !drop(*keyPtr, *valuePtr) {
+				continue
+			}
+
+			err = wb.Delete(item.KeyCopy(nil))
+			if err != nil {
+				return err
+			}
+			if nsm.txn.tracksExpiry() && item.ExpiresAt() != 0 {
+				err = wb.Delete(expiryIndexKey(item.ExpiresAt(), item.Key()))
+				if err != nil {
+					return err
+				}
+			}
+			dropped++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("CompactNamespaceDropping `%v`: %w", name, err)
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("CompactNamespaceDropping `%v`: %w", name, err)
+	}
+
+	err = db.badgerdb.Flatten(16)
+	if err != nil {
+		return dropped, fmt.Errorf("CompactNamespaceDropping `%v`: %w", name, err)
+	}
+
+	return dropped, nil
+}
+
+// Writes current values of all keys of namespace with passed name again, marking
+// their earlier versions discardable, so value log GC can reclaim space taken
+// by them sooner. Expiration of keys is kept. Values are read and written in
+// separate transactions, so it should not be called while namespace is written
+// by other transactions.
+func (db *DB[TxnAPIT]) RewriteNamespace(name string) error {
+	Txn{state: db.state}.validateNamespaceName(name)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefix := db.state.namespacePrefix(name)
+
+	wb := db.badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			valueb, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			entry := badger.NewEntry(item.KeyCopy(nil), valueb).WithDiscard()
+			entry.ExpiresAt = item.ExpiresAt()
+			entry.UserMeta = item.UserMeta()
+
+			err = wb.SetEntry(entry)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("RewriteNamespace `%v`: %w", name, err)
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return fmt.Errorf("RewriteNamespace `%v`: %w", name, err)
+	}
 
 	return nil
 }
 
 // Writes database backup to w. Consider adding compression before saving.
+// Version of database covered by backup is recorded for ViewAtLastBackup.
 func (db *DB[TxnAPIT]) Backup(w io.Writer) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	versionBefore := db.badgerdb.MaxVersion()
+
 	_, err := db.badgerdb.Backup(w, 0)
 	if err != nil {
 		return fmt.Errorf("Backup: %w", err)
 	}
 
+	err = db.recordBackupVersion(versionBefore)
+	if err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+
 	return nil
 }
 
+// Writes database backup as chunks of chunkSize bytes passed to sink, for
+// example to parts of multipart upload. All chunks except the last one have
+// exactly chunkSize bytes, the last one has isLast == true and up to chunkSize
+// bytes. Chunk is reused after sink returns, so sink must copy it to retain.
+func (db *DB[TxnAPIT]) BackupChunked(chunkSize int, sink func(chunk []byte, isLast bool) error) error {
+	if chunkSize <= 0 {
+		panic("chunkSize must be positive")
+	}
+	if sink == nil {
+		panic("sink must not be nil")
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cw := &chunkWriter{
+		buf:       make([]byte, 0, chunkSize),
+		chunkSize: chunkSize,
+		sink:      sink,
+	}
+
+	versionBefore := db.badgerdb.MaxVersion()
+
+	_, err := db.badgerdb.Backup(cw, 0)
+	if err != nil {
+		return fmt.Errorf("BackupChunked: %w", err)
+	}
+
+	err = sink(cw.buf, true)
+	if err != nil {
+		return fmt.Errorf("BackupChunked: %w", err)
+	}
+
+	err = db.recordBackupVersion(versionBefore)
+	if err != nil {
+		return fmt.Errorf("BackupChunked: %w", err)
+	}
+
+	return nil
+}
+
+// Splits written bytes into chunks. Full chunk is passed to sink only when
+// more bytes are written, so the last chunk is known to be last.
+type chunkWriter struct {
+	buf       []byte
+	chunkSize int
+	sink      func(chunk []byte, isLast bool) error
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		if len(cw.buf) == cw.chunkSize {
+			err := cw.sink(cw.buf, false)
+			if err != nil {
+				return n - len(p), err
+			}
+			cw.buf = cw.buf[:0]
+		}
+
+		free := cw.chunkSize - len(cw.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		cw.buf = append(cw.buf, p[:free]...)
+		p = p[free:]
+	}
+
+	return n, nil
+}
+
 // Replaces database storage with backup. Should be called when not running any
-// other transactions.
+// other transactions. Format version of backup is checked like on Open, so
+// backups written with format version 1 are rewritten only if WithRewriteKeys
+// is passed. IDs of interned namespaces are read from backup, and names not
+// interned in backup are interned like on Open.
 func (db *DB[TxnAPIT]) LoadBackup(r io.Reader) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	err := db.badgerdb.DropAll()
 	if err != nil {
 		return fmt.Errorf("LoadBackup: %w", err)
 	}
+	db.state.forgetSchemasIf(func(key string) bool { return true })
+	db.state.forgetTypeDescriptorsIf(func(key string) bool { return true })
 
 	err = db.badgerdb.Load(r, 64)
 	if err != nil {
 		return fmt.Errorf("LoadBackup: %w", err)
 	}
 
+	err = checkFormatVersion(db.badgerdb, db.dbopts.rewriteKeys)
+	if err != nil {
+		return fmt.Errorf("LoadBackup: %w", err)
+	}
+
+	namespaceIDs, err := internNamespaces(db.badgerdb, db.dbopts.internedNames)
+	if err != nil {
+		return fmt.Errorf("LoadBackup: %w", err)
+	}
+	db.state.namespaceIDs = namespaceIDs
+
 	err = db.badgerdb.Flatten(16)
 	if err != nil {
 		return fmt.Errorf("LoadBackup: %w", err)
@@ -129,14 +937,86 @@ func (db *DB[TxnAPIT]) LoadBackup(r io.Reader) error {
 }
 
 // Waits all pending transactions and closes database. You must call it to
-// ensure that all pending updates are written to disk.
+// ensure that all pending updates are written to disk. If database is shared
+// with facades created by WithTxnAPI, it is closed only with the last of them.
 func (db *DB[TxnAPIT]) Close() error {
+	if !db.releaseAndStopTasks() {
+		return nil
+	}
+	defer db.mu.Unlock()
+
+	db.stopExpiryRepeater()
 	db.stopGCRepeater()
 
-	err := db.badgerdb.Close()
+	err := db.close()
 	if err != nil {
 		return fmt.Errorf("Close: %w", err)
 	}
 
 	return nil
 }
+
+// Marks db closed and returns true if it was the last user of database.
+// Must be called with mu locked.
+func (db *DB[TxnAPIT]) release() bool {
+	if db.closed {
+		return false
+	}
+	db.closed = true
+	db.refs--
+
+	return db.refs == 0
+}
+
+func (db *DB[TxnAPIT]) close() error {
+	if db.unusable == nil {
+		err := markClosed(db.badgerdb)
+		if err != nil {
+			return err
+		}
+		err = db.badgerdb.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if db.tempDir != "" {
+		err := os.RemoveAll(db.tempDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Same as Close, but before closing runs value log garbage collection until
+// nothing is left to collect and flattens the database, so it takes minimal
+// space on disk. If database is shared with facades created by WithTxnAPI,
+// it is compacted and closed only with the last of them.
+func (db *DB[TxnAPIT]) CloseCompact() error {
+	if !db.releaseAndStopTasks() {
+		return nil
+	}
+	defer db.mu.Unlock()
+
+	db.stopExpiryRepeater()
+	db.stopGCRepeater()
+
+	if db.unusable == nil {
+		for db.badgerdb.RunValueLogGC(0.5) == nil {
+		}
+
+		err := db.badgerdb.Flatten(16)
+		if err != nil {
+			return fmt.Errorf("CloseCompact: %w", err)
+		}
+	}
+
+	err := db.close()
+	if err != nil {
+		return fmt.Errorf("CloseCompact: %w", err)
+	}
+
+	return nil
+}
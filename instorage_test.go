@@ -0,0 +1,18 @@
+package instorage
+
+import "testing"
+
+// Opens database in a temporary directory, which is closed after test
+func openTestDB(t testing.TB, opts ...Option) *DB[Txn] {
+	t.Helper()
+
+	db, err := Open(t.TempDir(), func(txn Txn) Txn { return txn }, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
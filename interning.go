@@ -0,0 +1,221 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Makes NamespaceMultiple and NamespaceSet with passed names store keys under
+// short fixed-length IDs instead of full names, which saves space for long
+// names. IDs are kept in a registry inside database. When a name is interned
+// for the first time, its existing data is moved under the ID. Once interned,
+// name must always be passed to this option, otherwise its data is not seen.
+func WithInternedNamespaces(names ...string) Option {
+	return func(dbopts *dbOptions) {
+		dbopts.internedNames = append(dbopts.internedNames, names...)
+	}
+}
+
+// Interned namespace keys start with this prefix followed by 4 bytes of ID.
+// It does not collide with reservedPrefix and encoded namespace names, which
+// never start with \x00 byte.
+var internedPrefix = []byte("\x00\x01")
+
+var namespaceIDCounterKey = reservedKey("namespace_id_counter")
+
+func namespaceIDKey(name string) []byte {
+	return reservedKey("namespace_id\x00" + string(encodeNamespaceName(name)))
+}
+
+// Registry keys of interned namespaces and their ID counter start with it
+var namespaceRegistryPrefix = reservedKey("namespace_id")
+
+// Returns values of registry keys of interned namespaces and their ID counter
+// by keys, so they may be stored again after all keys are deleted
+func readNamespaceRegistry(badgerdb *badger.DB) (map[string][]byte, error) {
+	registry := map[string][]byte{}
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(namespaceRegistryPrefix); it.ValidForPrefix(namespaceRegistryPrefix); it.Next() {
+			valueb, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			registry[string(it.Item().Key())] = valueb
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("readNamespaceRegistry: %w", err)
+	}
+
+	return registry, nil
+}
+
+// Stores registry returned by readNamespaceRegistry
+func storeNamespaceRegistry(badgerdb *badger.DB, registry map[string][]byte) error {
+	err := badgerdb.Update(func(badgertxn *badger.Txn) error {
+		for key, valueb := range registry {
+			err := badgertxn.Set([]byte(key), valueb)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("storeNamespaceRegistry: %w", err)
+	}
+
+	return nil
+}
+
+// Returns key prefixes of interned namespaces, assigning IDs to names, which
+// are not registered yet, and moving their data
+func internNamespaces(badgerdb *badger.DB, names []string) (map[string][]byte, error) {
+	ids := map[string][]byte{}
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	var missing []string
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		for _, name := range names {
+			if name == "" {
+				return fmt.Errorf("invalid namespace name `%v`", name)
+			}
+
+			item, err := badgertxn.Get(namespaceIDKey(name))
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					missing = append(missing, name)
+					continue
+				}
+				return err
+			}
+
+			idb, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			ids[name] = joinKey(internedPrefix, idb)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("internNamespaces: %w", err)
+	}
+
+	if len(missing) == 0 || badgerdb.Opts().ReadOnly {
+		return ids, nil
+	}
+
+	newIDs := map[string][]byte{}
+	err = badgerdb.Update(func(badgertxn *badger.Txn) error {
+		var counter uint32
+
+		item, err := badgertxn.Get(namespaceIDCounterKey)
+		if err == nil {
+			err = item.Value(func(counterb []byte) error {
+				ptr, ok, err := decodePrimitive[uint32](counterb)
+				if ok {
+					counter = *ptr
+				}
+				return err
+			})
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		for _, name := range missing {
+			counter++
+			idb := uint32Bytes(counter)
+
+			err = badgertxn.Set(namespaceIDKey(name), idb)
+			if err != nil {
+				return err
+			}
+			newIDs[name] = joinKey(internedPrefix, idb)
+		}
+
+		return badgertxn.Set(namespaceIDCounterKey, uint32Bytes(counter))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("internNamespaces: %w", err)
+	}
+
+	for name, prefix := range newIDs {
+		err = moveKeys(badgerdb, addPrefixToKey(encodeNamespaceName(name), nil), prefix)
+		if err != nil {
+			return nil, fmt.Errorf("internNamespaces: %w", err)
+		}
+		ids[name] = prefix
+	}
+
+	return ids, nil
+}
+
+// Moves all keys starting with oldPrefix under newPrefix in batches, keeping
+// their expiration and user metadata. Keys moved before error remain moved.
+func moveKeys(badgerdb *badger.DB, oldPrefix []byte, newPrefix []byte) error {
+	err := copyKeys(badgerdb, oldPrefix, newPrefix)
+	if err != nil {
+		return fmt.Errorf("moveKeys: %w", err)
+	}
+
+	err = badgerdb.DropPrefix(oldPrefix)
+	if err != nil {
+		return fmt.Errorf("moveKeys: %w", err)
+	}
+
+	return nil
+}
+
+// Copies all keys starting with oldPrefix under newPrefix in batches, keeping
+// their expiration and user metadata. Keys copied before error remain copied.
+func copyKeys(badgerdb *badger.DB, oldPrefix []byte, newPrefix []byte) error {
+	wb := badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(oldPrefix); it.ValidForPrefix(oldPrefix); it.Next() {
+			item := it.Item()
+
+			valueb, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			entry := badger.NewEntry(joinKey(newPrefix, item.Key()[len(oldPrefix):]), valueb).WithMeta(item.UserMeta())
+			entry.ExpiresAt = item.ExpiresAt()
+
+			err = wb.SetEntry(entry)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("copyKeys: %w", err)
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return fmt.Errorf("copyKeys: %w", err)
+	}
+
+	return nil
+}
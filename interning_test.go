@@ -0,0 +1,48 @@
+package instorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestMoveKeysKeepsMetaAndExpiry(t *testing.T) {
+	db := openTestDB(t)
+	badgerdb := db.Badger()
+
+	oldPrefix := addPrefixToKey(encodeNamespaceName("old"), nil)
+	newPrefix := addPrefixToKey(encodeNamespaceName("new"), nil)
+	expiresAt := uint64(time.Now().Add(time.Hour).Unix())
+
+	err := badgerdb.Update(func(badgertxn *badger.Txn) error {
+		entry := badger.NewEntry(joinKey(oldPrefix, []byte("key")), []byte("value")).WithMeta(7)
+		entry.ExpiresAt = expiresAt
+		return badgertxn.SetEntry(entry)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = moveKeys(badgerdb, oldPrefix, newPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = badgerdb.View(func(badgertxn *badger.Txn) error {
+		item, err := badgertxn.Get(joinKey(newPrefix, []byte("key")))
+		if err != nil {
+			return err
+		}
+		if item.UserMeta() != 7 {
+			t.Errorf("moved key has user metadata %v, expected 7", item.UserMeta())
+		}
+		if item.ExpiresAt() != expiresAt {
+			t.Errorf("moved key expires at %v, expected %v", item.ExpiresAt(), expiresAt)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,152 @@
+package instorage
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Iterates over all key-value pairs in this namespace with passed number of
+// workers. Key range of namespace is split into shards by boundaries of badger
+// tables, and shards are iterated by workers in their own goroutines, each
+// within its own read-only transaction. So cb is called concurrently and in no
+// particular order, and pairs written by transaction of nsm and not committed
+// yet are not seen. Namespaces, which fit in a single table, are iterated by a
+// single worker. Iteration stops at the first error returned by cb.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) IterParallel(workers int, cb func(key KeyT, value ValueT) error) error {
+	if workers <= 0 {
+		panic("workers must be positive")
+	}
+	defer nsm.txn.dbopts.observeOp("IterParallel", nsm.name, time.Now())
+
+	err := nsm.checkSchema(false)
+	if err != nil {
+		return fmt.Errorf("IterParallel `%v`: %w", nsm.name, err)
+	}
+
+	bounds := nsm.shardBounds()
+	shards := make(chan int, len(bounds)-1)
+	for shard := 0; shard < len(bounds)-1; shard++ {
+		shards <- shard
+	}
+	close(shards)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	failed := make(chan struct{})
+
+	for worker := 0; worker < workers && worker < len(bounds)-1; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for shard := range shards {
+				err := nsm.iterShard(bounds[shard], bounds[shard+1], failed, cb)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						close(failed)
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("IterParallel `%v`: %w", nsm.name, firstErr)
+	}
+
+	return nil
+}
+
+// Returns sorted boundaries of shards of namespace key range: namespace
+// prefix, biggest keys of badger tables within namespace and nil for the end
+// of namespace
+func (nsm *NamespaceMultiple[KeyT, ValueT]) shardBounds() [][]byte {
+	prefix := nsm.keyPrefix()
+
+	var splits [][]byte
+	for _, table := range nsm.txn.badgerdb.Tables() {
+		// Table keys end with 8 bytes of version
+		if len(table.Right) < 8 {
+			continue
+		}
+		right := table.Right[:len(table.Right)-8]
+		if bytes.HasPrefix(right, prefix) && len(right) > len(prefix) {
+			splits = append(splits, right)
+		}
+	}
+	sort.Slice(splits, func(i, j int) bool {
+		return bytes.Compare(splits[i], splits[j]) < 0
+	})
+
+	bounds := [][]byte{prefix}
+	for _, split := range splits {
+		if !bytes.Equal(split, bounds[len(bounds)-1]) {
+			bounds = append(bounds, split)
+		}
+	}
+
+	return append(bounds, nil)
+}
+
+// Calls cb for pairs of namespace with stored keys from start inclusive to end
+// exclusive, or to the end of namespace if end is nil, within a new read-only
+// transaction. Stops when failed is closed.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) iterShard(start []byte, end []byte, failed <-chan struct{}, cb func(key KeyT, value ValueT) error) error {
+	prefix := nsm.keyPrefix()
+
+	return nsm.txn.badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			select {
+			case <-failed:
+				return nil
+			default:
+			}
+
+			err := nsm.txn.checkContext()
+			if err != nil {
+				return err
+			}
+
+			item := it.Item()
+			k := item.Key()
+			if end != nil && bytes.Compare(k, end) >= 0 {
+				break
+			}
+			if nsm.txn.isExpired(item) {
+				continue
+			}
+
+			err = item.Value(func(valueb []byte) error {
+				keyPtr, err := decodeGob[KeyT](k[len(nsm.prefix):])
+				if err != nil {
+					return fmt.Errorf("decoding key rawKey=%x valueSize=%d: %w", k, len(valueb), err)
+				}
+				valuePtr, err := nsm.decodeValue(valueb)
+				if err != nil {
+					return fmt.Errorf("decoding value rawKey=%x valueSize=%d: %w", k, len(valueb), err)
+				}
+
+				return cb(*keyPtr, *valuePtr)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
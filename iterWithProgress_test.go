@@ -0,0 +1,75 @@
+package instorage
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Returns processed counts passed to progress while iterating n pairs
+func iterProgressCalls(t *testing.T, n int, every int) []int {
+	t.Helper()
+
+	db := openTestDB(t)
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, int](txn, "items")
+		for i := 0; i < n; i++ {
+			err := nsm.Set(i, i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []int
+	viewed := 0
+	err = db.View(func(txn Txn) error {
+		return NewNamespaceMultiple[int, int](txn, "items").IterWithProgress(every, func(processed int, estimatedTotal int64) {
+			calls = append(calls, processed)
+		}, func(key int, value int) (bool, error) {
+			viewed++
+			return false, nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viewed != n {
+		t.Fatalf("viewed %v pairs, expected %v", viewed, n)
+	}
+
+	return calls
+}
+
+func TestIterWithProgress(t *testing.T) {
+	for _, test := range []struct {
+		n     int
+		every int
+		want  []int
+	}{
+		{25, 10, []int{10, 20, 25}},
+		{20, 10, []int{10, 20}},
+		{0, 10, []int{0}},
+	} {
+		got := iterProgressCalls(t, test.n, test.every)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("progress of %v pairs every %v was called with %v, expected %v", test.n, test.every, got, test.want)
+		}
+	}
+}
+
+func TestIterWithProgressRejectsNotPositiveEvery(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.View(func(txn Txn) error {
+		return NewNamespaceMultiple[int, int](txn, "items").IterWithProgress(0, func(processed int, estimatedTotal int64) {}, func(key int, value int) (bool, error) {
+			return false, nil
+		})
+	})
+	if err == nil {
+		t.Fatal("IterWithProgress accepted zero every")
+	}
+}
@@ -0,0 +1,65 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Makes writes check, that encoded key is not already used by a different
+// key, and return ErrKeyCollision if it is. Go syntax representation of every
+// written key is stored alongside under reserved key and compared on the next
+// writes, so it doubles number of written keys and should be used for checking
+// key types before using them in production. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithCollisionCheck() *NamespaceMultiple[KeyT, ValueT] {
+	nsm.checkCollisions = true
+	return nsm
+}
+
+// Returns prefix of original keys of NamespaceMultiple with passed name
+func originalKeysPrefix(name string) []byte {
+	return addPrefixToKey(reservedKey("original_key\x00"+string(encodeNamespaceName(name))), nil)
+}
+
+// Returns key storing representation of original key encoded as keyb
+func (nsm *NamespaceMultiple[KeyT, ValueT]) originalKeyKey(keyb []byte) []byte {
+	return nsm.txn.scopedKey(joinKey(originalKeysPrefix(nsm.name), keyb))
+}
+
+// Stores representation of key encoded as keyb, returns ErrKeyCollision if
+// another key with the same encoding is stored already
+func (nsm *NamespaceMultiple[KeyT, ValueT]) checkKeyCollision(key KeyT, keyb []byte) error {
+	if !nsm.checkCollisions {
+		return nil
+	}
+
+	if nsm.normalizeKey != nil {
+		key = nsm.normalizeKey(key)
+	}
+	original := fmt.Sprintf("%#v", key)
+
+	item, err := nsm.txn.badgertxn.Get(nsm.originalKeyKey(keyb))
+	if err == nil {
+		return item.Value(func(storedb []byte) error {
+			if string(storedb) != original {
+				return fmt.Errorf("%w: %v and %s", ErrKeyCollision, original, storedb)
+			}
+			return nil
+		})
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+
+	return nsm.txn.badgertxn.Set(nsm.originalKeyKey(keyb), []byte(original))
+}
+
+// Deletes representation of key encoded as keyb stored by checkKeyCollision
+func (nsm *NamespaceMultiple[KeyT, ValueT]) forgetOriginalKey(keyb []byte) error {
+	if !nsm.checkCollisions {
+		return nil
+	}
+
+	return nsm.txn.badgertxn.Delete(nsm.originalKeyKey(keyb))
+}
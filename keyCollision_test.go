@@ -0,0 +1,62 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+)
+
+// Gob skips unexported fields, so keys differing only by hidden encode the same
+type collidingKey struct {
+	ID     int
+	hidden int
+}
+
+func TestCollisionCheckDetectsCollision(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[collidingKey, int](txn, "values").WithCollisionCheck()
+		err := nsm.Set(collidingKey{ID: 1, hidden: 1}, 1)
+		if err != nil {
+			return err
+		}
+		return nsm.Set(collidingKey{ID: 1, hidden: 2}, 2)
+	})
+	if !errors.Is(err, ErrKeyCollision) {
+		t.Fatalf("Set returned %v, want ErrKeyCollision", err)
+	}
+}
+
+func TestCollisionCheckForgetsDeletedKeys(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[collidingKey, int](txn, "values").WithCollisionCheck()
+
+		err := nsm.Set(collidingKey{ID: 1, hidden: 1}, 1)
+		if err != nil {
+			return err
+		}
+		err = nsm.Delete(collidingKey{ID: 1, hidden: 1})
+		if err != nil {
+			return err
+		}
+		err = nsm.Set(collidingKey{ID: 1, hidden: 2}, 2)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := nsm.CompareAndDelete(collidingKey{ID: 1, hidden: 2}, 2)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			t.Error("CompareAndDelete did not delete pair")
+		}
+
+		return nsm.Set(collidingKey{ID: 1, hidden: 3}, 3)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
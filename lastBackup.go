@@ -0,0 +1,87 @@
+package instorage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Returned by ViewAtLastBackup when database was changed after the last
+// backup, or no backup was recorded
+var ErrBackupStateChanged = errors.New("database changed since the last backup")
+
+// Stores database version covered by the last backup, written right after it
+var lastBackupVersionKey = reservedKey("last_backup_version")
+
+// Records version of backup made while database version was versionBefore.
+// Version is not recorded if database was changed while backup was written,
+// as it can not be known which of changes backup contains.
+func (db *DB[TxnAPIT]) recordBackupVersion(versionBefore uint64) error {
+	if db.badgerdb.Opts().ReadOnly {
+		return nil
+	}
+
+	return db.badgerdb.Update(func(badgertxn *badger.Txn) error {
+		if db.badgerdb.MaxVersion() != versionBefore {
+			return badgertxn.Delete(lastBackupVersionKey)
+		}
+
+		return badgertxn.Set(lastBackupVersionKey, uint64Bytes(versionBefore))
+	})
+}
+
+// Same as View, but runs viewer only if database is in the same state as when
+// the last backup was written by Backup or BackupChunked, otherwise returns
+// ErrBackupStateChanged. Badger can not read older versions of database, so
+// any write after backup, including writes made by eviction or expiry
+// callback, makes it fail until the next backup.
+func (db *DB[TxnAPIT]) ViewAtLastBackup(viewer func(txnAPI TxnAPIT) error) error {
+	err := db.viewTxn(context.Background(), func(txn Txn) error {
+		err := checkLastBackupState(txn)
+		if err != nil {
+			return err
+		}
+
+		return viewer(db.txnAPIBuilder(txn))
+	})
+	if err != nil {
+		return fmt.Errorf("ViewAtLastBackup: %w", err)
+	}
+
+	return nil
+}
+
+// Returns ErrBackupStateChanged, if transaction reads versions written after
+// the last backup. Marker of backup version is written right after backup, so
+// it must be the only write since then.
+func checkLastBackupState(txn Txn) error {
+	item, err := txn.badgertxn.Get(lastBackupVersionKey)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrBackupStateChanged
+		}
+
+		return err
+	}
+
+	var backupVersion uint64
+	err = item.Value(func(versionb []byte) error {
+		if len(versionb) != 8 {
+			return errors.New("malformed backup version")
+		}
+		backupVersion = binary.BigEndian.Uint64(versionb)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if item.Version() != backupVersion+1 || txn.ReadVersion() != item.Version() {
+		return ErrBackupStateChanged
+	}
+
+	return nil
+}
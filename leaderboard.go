@@ -0,0 +1,131 @@
+package instorage
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// IndexedNamespace indexed by integer score of values, which allows getting
+// pairs with the highest scores and ranks of keys
+type Leaderboard[KeyT comparable, ValueT any] struct {
+	*IndexedNamespace[KeyT, ValueT]
+}
+
+// Creates api for storing multiple key-value pairs ordered by score returned
+// by scoreOf. Scores are stored as index bytes of IndexedNamespace. Do not use
+// pointers as types for KeyT and ValueT. Name must not be empty.
+func NewLeaderboard[KeyT comparable, ValueT any](txn Txn, name string, scoreOf func(value ValueT) int64) *Leaderboard[KeyT, ValueT] {
+	if scoreOf == nil {
+		panic("scoreOf must not be nil")
+	}
+	return &Leaderboard[KeyT, ValueT]{
+		IndexedNamespace: NewIndexedNamespace[KeyT](txn, name, func(value ValueT) []byte {
+			return scoreBytes(scoreOf(value))
+		}),
+	}
+}
+
+// Returns up to n pairs with the highest scores, in descending order of score.
+// Pairs with equal scores are ordered by encoded keys in descending order.
+func (lb *Leaderboard[KeyT, ValueT]) TopN(n int) ([]KeyValue[KeyT, ValueT], error) {
+	var kvs []KeyValue[KeyT, ValueT]
+	if n <= 0 {
+		return kvs, nil
+	}
+
+	err := lb.iterDescending(func(keyb []byte) (bool, error) {
+		keyPtr, err := decodeGob[KeyT](keyb)
+		if err != nil {
+			return false, err
+		}
+
+		value, ok, err := lb.data.Get(*keyPtr)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			kvs = append(kvs, KeyValue[KeyT, ValueT]{
+				Key:   *keyPtr,
+				Value: value,
+			})
+		}
+
+		return len(kvs) == n, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TopN `%v`: %w", lb.data.name, err)
+	}
+
+	return kvs, nil
+}
+
+// Returns position of key in descending order of scores, as in TopN, starting
+// from 1. Returns 0 if key does not exist. Scans all pairs with higher scores.
+func (lb *Leaderboard[KeyT, ValueT]) Rank(key KeyT) (int, error) {
+	keyb, err := lb.data.encodeKey(key)
+	if err != nil {
+		return 0, fmt.Errorf("Rank `%v`: %w", lb.data.name, err)
+	}
+
+	rank := 0
+	found := false
+	err = lb.iterDescending(func(otherKeyb []byte) (bool, error) {
+		rank++
+		found = bytes.Equal(otherKeyb, keyb)
+		return found, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Rank `%v`: %w", lb.data.name, err)
+	}
+	if !found {
+		return 0, nil
+	}
+
+	return rank, nil
+}
+
+// Iterates over encoded keys of index entries from the highest score
+func (lb *Leaderboard[KeyT, ValueT]) iterDescending(viewer func(keyb []byte) (stop bool, err error)) error {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Reverse = true
+	iteratorOptions.PrefetchValues = false
+	iteratorOptions.Prefix = lb.indexPrefix
+
+	it := lb.data.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	// Index prefix ends with \x00, so replacing it with \x01 gives key right
+	// after all index entries
+	seekKey := joinKey(lb.indexPrefix, nil)
+	seekKey[len(seekKey)-1] = 0x01
+
+	for it.Seek(seekKey); it.ValidForPrefix(lb.indexPrefix); it.Next() {
+		err := lb.data.txn.checkContext()
+		if err != nil {
+			return err
+		}
+
+		keyb, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		stop, err := viewer(keyb)
+		if err != nil {
+			return err
+		}
+		if stop {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Encodes score as big-endian bytes with inverted sign bit, so byte order of
+// encoded scores matches their numeric order
+func scoreBytes(score int64) []byte {
+	return uint64Bytes(uint64(score) ^ (1 << 63))
+}
@@ -0,0 +1,51 @@
+package instorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Replaces value of key in NamespaceMultiple with passed name by value
+// returned by apply, in its own transaction. apply receives current value, or
+// exists == false if key does not exist. If transaction conflicts with
+// concurrent write, current value is read again and apply is called again with
+// it, up to maxAttempts times in total, so apply must not have side effects.
+// If all attempts conflict, badger.ErrConflict is returned wrapped.
+func MergeUpdate[TxnAPIT any, KeyT comparable, ValueT any](db *DB[TxnAPIT], name string, key KeyT, apply func(current ValueT, exists bool) (ValueT, error), maxAttempts int) error {
+	if apply == nil {
+		panic("apply must not be nil")
+	}
+	if maxAttempts < 1 {
+		panic("maxAttempts must be positive")
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = db.updateTxn(context.Background(), func(txn Txn) error {
+			nsm := NewNamespaceMultiple[KeyT, ValueT](txn, name)
+
+			current, exists, err := nsm.Get(key)
+			if err != nil {
+				return err
+			}
+
+			merged, err := apply(current, exists)
+			if err != nil {
+				return err
+			}
+
+			return nsm.Set(key, merged)
+		})
+		if !errors.Is(err, badger.ErrConflict) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("MergeUpdate `%v`: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package instorage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Key-value pairs of NamespaceMultiple bound to database and mirrored in a Go
+// map, so Get does not start a transaction and reads at map speed. The whole
+// namespace is kept in memory, so it suits only small read-mostly namespaces,
+// like lookup tables. Set and Delete write to database first and update map
+// after commit. Mirror assumes it is the only writer of namespace: changes
+// written by other transactions or MirroredNamespace instances are not seen
+// until Reload. Safe for concurrent use.
+type MirroredNamespace[KeyT comparable, ValueT any] struct {
+	name string
+	// Run fn within read-only and read-write transaction of database
+	view   func(fn func(txn Txn) error) error
+	update func(fn func(txn Txn) error) error
+
+	mu     sync.RWMutex
+	values map[KeyT]ValueT
+}
+
+// Creates mirror of NamespaceMultiple with passed name and loads all its pairs
+// into memory. Do not use pointers as types for KeyT and ValueT. Name must not
+// be empty.
+func NewMirroredNamespace[TxnAPIT any, KeyT comparable, ValueT any](db *DB[TxnAPIT], name string) (*MirroredNamespace[KeyT, ValueT], error) {
+	Txn{state: db.state}.validateNamespaceName(name)
+	mn := &MirroredNamespace[KeyT, ValueT]{
+		name: name,
+		view: func(fn func(txn Txn) error) error {
+			return db.viewTxn(context.Background(), fn)
+		},
+		update: func(fn func(txn Txn) error) error {
+			return db.updateTxn(context.Background(), fn)
+		},
+	}
+
+	err := mn.Reload()
+	if err != nil {
+		return nil, fmt.Errorf("NewMirroredNamespace: %w", err)
+	}
+
+	return mn, nil
+}
+
+// Returns value stored under a key from memory. Returns ok == false if key
+// does not exist.
+func (mn *MirroredNamespace[KeyT, ValueT]) Get(key KeyT) (value ValueT, ok bool) {
+	mn.mu.RLock()
+	defer mn.mu.RUnlock()
+
+	value, ok = mn.values[key]
+	return value, ok
+}
+
+// Returns number of mirrored pairs
+func (mn *MirroredNamespace[KeyT, ValueT]) Len() int {
+	mn.mu.RLock()
+	defer mn.mu.RUnlock()
+
+	return len(mn.values)
+}
+
+// Sets value under a key within a new transaction, and in memory once it is
+// committed
+func (mn *MirroredNamespace[KeyT, ValueT]) Set(key KeyT, value ValueT) error {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	err := mn.update(func(txn Txn) error {
+		return NewNamespaceMultiple[KeyT, ValueT](txn, mn.name).Set(key, value)
+	})
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", mn.name, err)
+	}
+	mn.values[key] = value
+
+	return nil
+}
+
+// Deletes key within a new transaction, and from memory once it is committed.
+// No error is returned, if key does not exist.
+func (mn *MirroredNamespace[KeyT, ValueT]) Delete(key KeyT) error {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	err := mn.update(func(txn Txn) error {
+		return NewNamespaceMultiple[KeyT, ValueT](txn, mn.name).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", mn.name, err)
+	}
+	delete(mn.values, key)
+
+	return nil
+}
+
+// Reads all pairs of namespace from database again, replacing mirrored ones
+func (mn *MirroredNamespace[KeyT, ValueT]) Reload() error {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	values := make(map[KeyT]ValueT)
+	err := mn.view(func(txn Txn) error {
+		return NewNamespaceMultiple[KeyT, ValueT](txn, mn.name).Iter(func(key KeyT, value ValueT) (bool, error) {
+			values[key] = value
+			return false, nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("Reload `%v`: %w", mn.name, err)
+	}
+	mn.values = values
+
+	return nil
+}
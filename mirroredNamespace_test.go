@@ -0,0 +1,109 @@
+package instorage
+
+import "testing"
+
+func TestMirroredNamespace(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[string, int](txn, "lookup").Set("a", 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mn, err := NewMirroredNamespace[Txn, string, int](db, "lookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := mn.Get("a"); !ok || value != 1 {
+		t.Errorf("Get(a) = %v, %v, expected 1 loaded from database", value, ok)
+	}
+
+	err = mn.Set("b", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mn.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mn.Get("a"); ok {
+		t.Error("deleted key a is still mirrored")
+	}
+	if mn.Len() != 1 {
+		t.Errorf("Len() = %v, expected 1", mn.Len())
+	}
+
+	err = db.View(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, int](txn, "lookup")
+		if _, ok, err := nsm.Get("a"); err != nil || ok {
+			t.Errorf("deleted key a is stored: %v, %v", ok, err)
+		}
+		if value, ok, err := nsm.Get("b"); err != nil || !ok || value != 2 {
+			t.Errorf("Get(b) from database = %v, %v, %v, expected 2", value, ok, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMirroredNamespaceReload(t *testing.T) {
+	db := openTestDB(t)
+
+	mn, err := NewMirroredNamespace[Txn, string, int](db, "lookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[string, int](txn, "lookup").Set("a", 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mn.Get("a"); ok {
+		t.Error("write of other transaction is mirrored before Reload")
+	}
+
+	err = mn.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := mn.Get("a"); !ok || value != 1 {
+		t.Errorf("Get(a) after Reload = %v, %v, expected 1", value, ok)
+	}
+}
+
+func TestMirroredNamespaceKeepsMapOnFailedWrite(t *testing.T) {
+	db := openTestDB(t)
+
+	mn, err := NewMirroredNamespace[Txn, string, int](db, "lookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mn.Set("a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mn.Set("b", 2) == nil {
+		t.Fatal("Set succeeded on closed database")
+	}
+	if mn.Delete("a") == nil {
+		t.Fatal("Delete succeeded on closed database")
+	}
+	if _, ok := mn.Get("b"); ok {
+		t.Error("failed Set is mirrored")
+	}
+	if _, ok := mn.Get("a"); !ok {
+		t.Error("failed Delete is mirrored")
+	}
+}
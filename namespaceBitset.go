@@ -0,0 +1,306 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Size of chunks NamespaceBitset is split into, in bytes
+const bitsetChunkSize = 4096
+
+const bitsetChunkBits = bitsetChunkSize * 8
+
+// Stores set of integer IDs as bitmap split into chunks, so changing single ID
+// rewrites only its chunk. Chunks without IDs are not stored, but every stored
+// chunk takes bitsetChunkSize bytes, even if it has a single ID, so it suits
+// dense sets, and sparse sets take up to 4KB per ID.
+type NamespaceBitset struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing set of integer IDs under same namespace. Name must
+// not be empty.
+func NewNamespaceBitset(txn Txn, name string) *NamespaceBitset {
+	txn.validateNamespaceName(name)
+	return &NamespaceBitset{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Adds id to the set
+func (nsb *NamespaceBitset) Set(id uint64) error {
+	err := nsb.updateBit(id, true)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsb.name, err)
+	}
+
+	return nil
+}
+
+// Removes id from the set. No error is returned, if id is not in the set.
+func (nsb *NamespaceBitset) Clear(id uint64) error {
+	err := nsb.updateBit(id, false)
+	if err != nil {
+		return fmt.Errorf("Clear `%v`: %w", nsb.name, err)
+	}
+
+	return nil
+}
+
+// Reports whether id is in the set
+func (nsb *NamespaceBitset) Test(id uint64) (bool, error) {
+	chunk, err := nsb.readChunk(id / bitsetChunkBits)
+	if err != nil {
+		return false, fmt.Errorf("Test `%v`: %w", nsb.name, err)
+	}
+	if chunk == nil {
+		return false, nil
+	}
+
+	bit := id % bitsetChunkBits
+	return chunk[bit/8]&(1<<(bit%8)) != 0, nil
+}
+
+// Returns number of IDs in the set
+func (nsb *NamespaceBitset) Count() (uint64, error) {
+	var count uint64
+	err := nsb.iterChunks(func(chunkIndex uint64, chunk []byte) error {
+		for _, b := range chunk {
+			count += uint64(bits.OnesCount8(b))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Count `%v`: %w", nsb.name, err)
+	}
+
+	return count, nil
+}
+
+// Iterates over IDs in ascending order. If viewer function returns
+// stop == true, then iteration stops.
+func (nsb *NamespaceBitset) Iter(viewer func(id uint64) (stop bool, err error)) error {
+	errStop := errors.New("stop")
+
+	err := nsb.iterChunks(func(chunkIndex uint64, chunk []byte) error {
+		for i, b := range chunk {
+			for b != 0 {
+				bit := uint64(bits.TrailingZeros8(b))
+				b &= b - 1
+
+				stop, err := viewer(chunkIndex*bitsetChunkBits + uint64(i)*8 + bit)
+				if err != nil {
+					return err
+				}
+				if stop {
+					return errStop
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return fmt.Errorf("Iter `%v`: %w", nsb.name, err)
+	}
+
+	return nil
+}
+
+// Adds all IDs of other to this set
+func (nsb *NamespaceBitset) UnionWith(other *NamespaceBitset) error {
+	err := nsb.combine(other, func(a, b byte) byte { return a | b }, false)
+	if err != nil {
+		return fmt.Errorf("UnionWith `%v`: %w", nsb.name, err)
+	}
+
+	return nil
+}
+
+// Removes IDs, which are not in other, from this set
+func (nsb *NamespaceBitset) IntersectWith(other *NamespaceBitset) error {
+	err := nsb.combine(other, func(a, b byte) byte { return a & b }, true)
+	if err != nil {
+		return fmt.Errorf("IntersectWith `%v`: %w", nsb.name, err)
+	}
+
+	return nil
+}
+
+// Removes all IDs of other from this set
+func (nsb *NamespaceBitset) DifferenceWith(other *NamespaceBitset) error {
+	err := nsb.combine(other, func(a, b byte) byte { return a &^ b }, false)
+	if err != nil {
+		return fmt.Errorf("DifferenceWith `%v`: %w", nsb.name, err)
+	}
+
+	return nil
+}
+
+// Replaces chunks of this set with op applied to them and chunks of other.
+// Missing chunks are treated as empty. If clearMissing is true, chunks missing
+// in other are cleared too, otherwise they are left unchanged.
+func (nsb *NamespaceBitset) combine(other *NamespaceBitset, op func(a, b byte) byte, clearMissing bool) error {
+	otherChunks := map[uint64][]byte{}
+	err := other.iterChunks(func(chunkIndex uint64, chunk []byte) error {
+		otherChunks[chunkIndex] = append([]byte(nil), chunk...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if clearMissing {
+		var missing []uint64
+		err = nsb.iterChunks(func(chunkIndex uint64, chunk []byte) error {
+			if _, ok := otherChunks[chunkIndex]; !ok {
+				missing = append(missing, chunkIndex)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, chunkIndex := range missing {
+			err = nsb.writeChunk(chunkIndex, make([]byte, bitsetChunkSize))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for chunkIndex, otherChunk := range otherChunks {
+		chunk, err := nsb.readChunk(chunkIndex)
+		if err != nil {
+			return err
+		}
+		if chunk == nil {
+			chunk = make([]byte, bitsetChunkSize)
+		}
+
+		for i := range chunk {
+			chunk[i] = op(chunk[i], otherChunk[i])
+		}
+
+		err = nsb.writeChunk(chunkIndex, chunk)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (nsb *NamespaceBitset) updateBit(id uint64, value bool) error {
+	chunkIndex := id / bitsetChunkBits
+
+	chunk, err := nsb.readChunk(chunkIndex)
+	if err != nil {
+		return err
+	}
+	if chunk == nil {
+		if !value {
+			return nil
+		}
+		chunk = make([]byte, bitsetChunkSize)
+	}
+
+	bit := id % bitsetChunkBits
+	if value {
+		chunk[bit/8] |= 1 << (bit % 8)
+	} else {
+		chunk[bit/8] &^= 1 << (bit % 8)
+	}
+
+	return nsb.writeChunk(chunkIndex, chunk)
+}
+
+func (nsb *NamespaceBitset) chunkKey(chunkIndex uint64) []byte {
+	return joinKey(nsb.prefix, uint64Bytes(chunkIndex))
+}
+
+// Returned for stored chunks of unexpected size, which are not written by
+// NamespaceBitset
+var errMalformedChunk = errors.New("malformed bitset chunk")
+
+func checkChunk(chunkIndex uint64, chunk []byte) error {
+	if len(chunk) != bitsetChunkSize {
+		return fmt.Errorf("%w: chunk %v has %v bytes, expected %v", errMalformedChunk, chunkIndex, len(chunk), bitsetChunkSize)
+	}
+
+	return nil
+}
+
+// Returns copy of chunk, or nil if chunk is not stored
+func (nsb *NamespaceBitset) readChunk(chunkIndex uint64) ([]byte, error) {
+	item, err := nsb.txn.badgertxn.Get(nsb.chunkKey(chunkIndex))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	chunk, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	err = checkChunk(chunkIndex, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+// Stores chunk, or deletes it if it has no IDs
+func (nsb *NamespaceBitset) writeChunk(chunkIndex uint64, chunk []byte) error {
+	for _, b := range chunk {
+		if b != 0 {
+			return nsb.txn.badgertxn.Set(nsb.chunkKey(chunkIndex), chunk)
+		}
+	}
+
+	return nsb.txn.badgertxn.Delete(nsb.chunkKey(chunkIndex))
+}
+
+func (nsb *NamespaceBitset) iterChunks(viewer func(chunkIndex uint64, chunk []byte) error) error {
+	it := nsb.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(nsb.prefix); it.ValidForPrefix(nsb.prefix); it.Next() {
+		err := nsb.txn.checkContext()
+		if err != nil {
+			return err
+		}
+
+		item := it.Item()
+		indexb := item.Key()[len(nsb.prefix):]
+		if len(indexb) != 8 {
+			return fmt.Errorf("%w: key %x", errMalformedChunk, item.Key())
+		}
+		chunkIndex := binary.BigEndian.Uint64(indexb)
+
+		err = item.Value(func(chunk []byte) error {
+			err := checkChunk(chunkIndex, chunk)
+			if err != nil {
+				return err
+			}
+			return viewer(chunkIndex, chunk)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNamespaceBitset(t *testing.T) {
+	db := openTestDB(t)
+
+	ids := []uint64{0, 7, bitsetChunkBits - 1, bitsetChunkBits, 5 * bitsetChunkBits}
+	err := db.Update(func(txn Txn) error {
+		nsb := NewNamespaceBitset(txn, "ids")
+		for _, id := range ids {
+			err := nsb.Set(id)
+			if err != nil {
+				return err
+			}
+		}
+		return nsb.Clear(7)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		nsb := NewNamespaceBitset(txn, "ids")
+		count, err := nsb.Count()
+		if err != nil {
+			return err
+		}
+		if count != 4 {
+			t.Errorf("Count() = %v, expected 4", count)
+		}
+		for _, id := range ids {
+			ok, err := nsb.Test(id)
+			if err != nil {
+				return err
+			}
+			if ok != (id != 7) {
+				t.Errorf("Test(%v) = %v", id, ok)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespaceBitsetRejectsMalformedChunk(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return txn.badgertxn.Set(NewNamespaceBitset(txn, "ids").chunkKey(0), []byte{0xFF})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		nsb := NewNamespaceBitset(txn, "ids")
+		_, err := nsb.Test(bitsetChunkBits - 1)
+		if !errors.Is(err, errMalformedChunk) {
+			t.Errorf("Test returned %v for short chunk, expected errMalformedChunk", err)
+		}
+		_, err = nsb.Count()
+		if !errors.Is(err, errMalformedChunk) {
+			t.Errorf("Count returned %v for short chunk, expected errMalformedChunk", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,167 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores values under compound keys made of two parts. Keys are stored as gob
+// encoded first part, \x00 separator and gob encoded second part, so all pairs
+// with the same first part are stored next to each other. Gob encoding of a
+// value is self-delimiting, so encoding of one first part is never a prefix of
+// encoding of another.
+type NamespaceCompound[A comparable, B comparable, ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing values under compound keys under same namespace. Do
+// not use pointers as types for A, B and ValueT. Name must not be empty.
+func NewNamespaceCompound[A comparable, B comparable, ValueT any](txn Txn, name string) *NamespaceCompound[A, B, ValueT] {
+	txn.validateNamespaceName(name)
+	return &NamespaceCompound[A, B, ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Sets a new value for a compound key
+func (nsc *NamespaceCompound[A, B, ValueT]) Set(a A, b B, value ValueT) error {
+	key, err := nsc.key(a, b)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsc.name, err)
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsc.name, err)
+	}
+
+	err = nsc.txn.badgertxn.Set(key, valueb)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsc.name, err)
+	}
+
+	return nil
+}
+
+// Returns value stored under a compound key. Returns ok == false if key does
+// not exist.
+func (nsc *NamespaceCompound[A, B, ValueT]) Get(a A, b B) (value ValueT, ok bool, err error) {
+	key, err := nsc.key(a, b)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsc.name, err)
+	}
+
+	item, err := nsc.txn.badgertxn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return value, false, nil
+		}
+
+		return value, false, fmt.Errorf("Get `%v`: %w", nsc.name, err)
+	}
+
+	var valuePtr *ValueT
+	err = item.Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = decodeGob[ValueT](valueb)
+		return err
+	})
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsc.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Deletes value stored under a compound key. No error is returned, if key does
+// not exist.
+func (nsc *NamespaceCompound[A, B, ValueT]) Delete(a A, b B) error {
+	key, err := nsc.key(a, b)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsc.name, err)
+	}
+
+	err = nsc.txn.badgertxn.Delete(key)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsc.name, err)
+	}
+
+	return nil
+}
+
+// Iterates over second parts of keys and values stored with passed first part,
+// in order of encoded second parts. If viewer function returns stop == true,
+// then iteration stops.
+func (nsc *NamespaceCompound[A, B, ValueT]) IterByA(a A, viewer func(b B, value ValueT) (stop bool, err error)) error {
+	prefixA, err := nsc.prefixA(a)
+	if err != nil {
+		return fmt.Errorf("IterByA `%v`: %w", nsc.name, err)
+	}
+
+	it := nsc.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(prefixA); it.ValidForPrefix(prefixA); it.Next() {
+		err := nsc.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterByA `%v`: %w", nsc.name, err)
+		}
+
+		item := it.Item()
+		bPtr, err := decodeGob[B](item.Key()[len(prefixA):])
+		if err != nil {
+			return fmt.Errorf("IterByA `%v`: %w", nsc.name, err)
+		}
+
+		var stop bool
+		err = item.Value(func(valueb []byte) error {
+			valuePtr, err := decodeGob[ValueT](valueb)
+			if err != nil {
+				return err
+			}
+
+			stop, err = viewer(*bPtr, *valuePtr)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("IterByA `%v`: %w", nsc.name, err)
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Returns prefix of keys with passed first part: namespace prefix, encoded
+// first part and \x00 separator
+func (nsc *NamespaceCompound[A, B, ValueT]) prefixA(a A) ([]byte, error) {
+	ab, err := encodeGob(a)
+	if err != nil {
+		return nil, err
+	}
+
+	return addPrefixToKey(joinKey(nsc.prefix, ab), nil), nil
+}
+
+func (nsc *NamespaceCompound[A, B, ValueT]) key(a A, b B) ([]byte, error) {
+	prefixA, err := nsc.prefixA(a)
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := encodeGob(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinKey(prefixA, bb), nil
+}
@@ -0,0 +1,140 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores values under sequence numbers assigned in order of insertion, so they
+// may be read from the newest one without sorting. Sequence numbers are never
+// reused, even after values are deleted.
+type NamespaceFeed[ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing values in insertion order under same namespace. Do
+// not use pointer as a type for ValueT. Name must not be empty.
+func NewNamespaceFeed[ValueT any](txn Txn, name string) *NamespaceFeed[ValueT] {
+	txn.validateNamespaceName(name)
+	return &NamespaceFeed[ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Appends value and returns its sequence number, starting from 1. Concurrent
+// transactions appending values conflict, so only one of them is committed.
+func (nsf *NamespaceFeed[ValueT]) Append(value ValueT) (seq uint64, err error) {
+	// Sequence number is stored under namespace prefix, right before values
+	seq, err = nextSeq(nsf.txn, nsf.prefix)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsf.name, err)
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsf.name, err)
+	}
+
+	err = nsf.txn.badgertxn.Set(joinKey(nsf.prefix, uint64Bytes(seq)), valueb)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsf.name, err)
+	}
+
+	return seq, nil
+}
+
+// Returns value with passed sequence number. Returns ok == false if it does
+// not exist.
+func (nsf *NamespaceFeed[ValueT]) Get(seq uint64) (value ValueT, ok bool, err error) {
+	item, err := nsf.txn.badgertxn.Get(joinKey(nsf.prefix, uint64Bytes(seq)))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return value, false, nil
+		}
+
+		return value, false, fmt.Errorf("Get `%v`: %w", nsf.name, err)
+	}
+
+	var valuePtr *ValueT
+	err = item.Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = decodeGob[ValueT](valueb)
+		return err
+	})
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsf.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Deletes value with passed sequence number. No error is returned, if it does
+// not exist.
+func (nsf *NamespaceFeed[ValueT]) Delete(seq uint64) error {
+	err := nsf.txn.badgertxn.Delete(joinKey(nsf.prefix, uint64Bytes(seq)))
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsf.name, err)
+	}
+
+	return nil
+}
+
+// Iterates over up to limit values from the newest one, or over all of them
+// if limit is not positive. If viewer function returns stop == true, then
+// iteration stops.
+func (nsf *NamespaceFeed[ValueT]) IterNewest(limit int, viewer func(seq uint64, value ValueT) (stop bool, err error)) error {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Reverse = true
+	it := nsf.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	seen := 0
+	for it.Seek(joinKey(nsf.prefix, uint64Bytes(^uint64(0)))); it.ValidForPrefix(nsf.prefix); it.Next() {
+		if limit > 0 && seen == limit {
+			break
+		}
+
+		err := nsf.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterNewest `%v`: %w", nsf.name, err)
+		}
+
+		item := it.Item()
+		// Sequence counter is the last key in reverse order
+		if len(item.Key()) == len(nsf.prefix) {
+			break
+		}
+		if nsf.txn.isExpired(item) {
+			continue
+		}
+		seq := binary.BigEndian.Uint64(item.Key()[len(nsf.prefix):])
+
+		var stop bool
+		err = item.Value(func(valueb []byte) error {
+			valuePtr, err := decodeGob[ValueT](valueb)
+			if err != nil {
+				return err
+			}
+
+			stop, err = viewer(seq, *valuePtr)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("IterNewest `%v`: %w", nsf.name, err)
+		}
+		seen++
+
+		if stop {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores values in groups under same namespace. Values of a group are stored
+// next to each other, so the whole group is read or deleted with a single
+// prefix scan.
+type NamespaceGrouped[ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing grouped values under same namespace. Do not use
+// pointer as a type for ValueT. Name must not be empty.
+func NewNamespaceGrouped[ValueT any](txn Txn, name string) *NamespaceGrouped[ValueT] {
+	txn.validateNamespaceName(name)
+	return &NamespaceGrouped[ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Appends value to the group and returns its sequence number within the
+// group, starting from 1. Group must not contain \x00 symbol.
+func (nsg *NamespaceGrouped[ValueT]) Append(group string, value ValueT) (seq uint64, err error) {
+	groupPrefix, err := nsg.groupPrefix(group)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsg.name, err)
+	}
+
+	seq, err = nsg.lastSeq(groupPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsg.name, err)
+	}
+	seq++
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsg.name, err)
+	}
+
+	err = nsg.txn.badgertxn.Set(joinKey(groupPrefix, uint64Bytes(seq)), valueb)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsg.name, err)
+	}
+
+	return seq, nil
+}
+
+// Returns value with passed sequence number in the group. Returns ok == false
+// if it does not exist.
+func (nsg *NamespaceGrouped[ValueT]) Get(group string, seq uint64) (value ValueT, ok bool, err error) {
+	groupPrefix, err := nsg.groupPrefix(group)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsg.name, err)
+	}
+
+	item, err := nsg.txn.badgertxn.Get(joinKey(groupPrefix, uint64Bytes(seq)))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return value, false, nil
+		}
+
+		return value, false, fmt.Errorf("Get `%v`: %w", nsg.name, err)
+	}
+
+	var valuePtr *ValueT
+	err = item.Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = decodeGob[ValueT](valueb)
+		return err
+	})
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsg.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Iterates over values of the group in order they were appended. If viewer
+// function returns stop == true, then iteration stops.
+func (nsg *NamespaceGrouped[ValueT]) IterGroup(group string, viewer func(seq uint64, value ValueT) (stop bool, err error)) error {
+	groupPrefix, err := nsg.groupPrefix(group)
+	if err != nil {
+		return fmt.Errorf("IterGroup `%v`: %w", nsg.name, err)
+	}
+
+	it := nsg.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(groupPrefix); it.ValidForPrefix(groupPrefix); it.Next() {
+		err := nsg.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterGroup `%v`: %w", nsg.name, err)
+		}
+
+		item := it.Item()
+		seq := binary.BigEndian.Uint64(item.Key()[len(groupPrefix):])
+
+		var stop bool
+		err = item.Value(func(valueb []byte) error {
+			valuePtr, err := decodeGob[ValueT](valueb)
+			if err != nil {
+				return err
+			}
+
+			stop, err = viewer(seq, *valuePtr)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("IterGroup `%v`: %w", nsg.name, err)
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Deletes all values of the group within transaction. Only keys of the group
+// are scanned, values are not read. Every value is deleted separately, so big
+// groups may not fit in one transaction and fail with badger.ErrTxnTooBig; use
+// DB.DropGroup to delete groups of any size.
+func (nsg *NamespaceGrouped[ValueT]) DeleteGroup(group string) error {
+	groupPrefix, err := nsg.groupPrefix(group)
+	if err != nil {
+		return fmt.Errorf("DeleteGroup `%v`: %w", nsg.name, err)
+	}
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := nsg.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var keys [][]byte
+	for it.Seek(groupPrefix); it.ValidForPrefix(groupPrefix); it.Next() {
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+
+	for _, key := range keys {
+		err = nsg.txn.badgertxn.Delete(key)
+		if err != nil {
+			return fmt.Errorf("DeleteGroup `%v`: %w", nsg.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Deletes all values of the group of NamespaceGrouped with passed name with a
+// single prefix deletion, outside of transactions, so group may be of any
+// size. Concurrent transactions appending to the group may keep their values.
+func (db *DB[TxnAPIT]) DropGroup(name string, group string) error {
+	Txn{state: db.state}.validateNamespaceName(name)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	groupPrefix, err := groupKeyPrefix(db.state.namespacePrefix(name), group)
+	if err != nil {
+		return fmt.Errorf("DropGroup `%v`: %w", name, err)
+	}
+
+	err = db.badgerdb.DropPrefix(groupPrefix)
+	if err != nil {
+		return fmt.Errorf("DropGroup `%v`: %w", name, err)
+	}
+
+	return nil
+}
+
+func (nsg *NamespaceGrouped[ValueT]) groupPrefix(group string) ([]byte, error) {
+	return groupKeyPrefix(nsg.prefix, group)
+}
+
+// Returns prefix of keys stored in the group: namespace prefix, group and
+// \x00 separator
+func groupKeyPrefix(namespacePrefix []byte, group string) ([]byte, error) {
+	if strings.ContainsRune(group, '\x00') {
+		return nil, errors.New("group must not contain \\x00 symbol")
+	}
+
+	return addPrefixToKey(joinKey(namespacePrefix, []byte(group)), nil), nil
+}
+
+// Returns the last sequence number in the group, or 0 if group is empty
+func (nsg *NamespaceGrouped[ValueT]) lastSeq(groupPrefix []byte) (uint64, error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Reverse = true
+	iteratorOptions.PrefetchValues = false
+	it := nsg.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	it.Seek(joinKey(groupPrefix, uint64Bytes(^uint64(0))))
+	if !it.ValidForPrefix(groupPrefix) {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint64(it.Item().Key()[len(groupPrefix):]), nil
+}
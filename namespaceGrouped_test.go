@@ -0,0 +1,51 @@
+package instorage
+
+import "testing"
+
+func TestDropGroup(t *testing.T) {
+	db := openTestDB(t)
+
+	// Appended in batches, as the dropped group does not fit in one transaction
+	for batch := 0; batch < 10; batch++ {
+		err := db.Update(func(txn Txn) error {
+			nsg := NewNamespaceGrouped[int](txn, "logs")
+			for i := 0; i < 1000; i++ {
+				_, err := nsg.Append("request", i)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := nsg.Append("request_kept", batch)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := db.DropGroup("logs", "request")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	err = db.View(func(txn Txn) error {
+		nsg := NewNamespaceGrouped[int](txn, "logs")
+		for _, group := range []string{"request", "request_kept"} {
+			err := nsg.IterGroup(group, func(seq uint64, value int) (bool, error) {
+				counts[group]++
+				return false, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["request"] != 0 || counts["request_kept"] != 10 {
+		t.Errorf("groups have %v values after drop, expected request_kept with 10", counts)
+	}
+}
@@ -0,0 +1,272 @@
+package instorage
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores multiple key-value pairs under same namespace along with index of
+// values, which allows scanning pairs by ranges of index bytes
+type IndexedNamespace[KeyT comparable, ValueT any] struct {
+	data        *NamespaceMultiple[KeyT, ValueT]
+	indexOf     func(value ValueT) []byte
+	indexPrefix []byte
+	// Set rejects values with index bytes of another key
+	uniqueValues bool
+}
+
+// Creates api for storing multiple key-value pairs under same namespace with
+// index. indexOf returns sortable index bytes for a value, for example Z-order
+// curve of coordinates. Index is stored separately from namespace data and is
+// removed with it by DB.DropNamespace. Do not use pointers as types for KeyT
+// and ValueT. Name must not be empty.
+func NewIndexedNamespace[KeyT comparable, ValueT any](txn Txn, name string, indexOf func(value ValueT) []byte) *IndexedNamespace[KeyT, ValueT] {
+	if indexOf == nil {
+		panic("indexOf must not be nil")
+	}
+	return &IndexedNamespace[KeyT, ValueT]{
+		data:        NewNamespaceMultiple[KeyT, ValueT](txn, name),
+		indexOf:     indexOf,
+		indexPrefix: txn.scopedKey(indexPrefix(name)),
+	}
+}
+
+// Makes Set fail with ErrDuplicateValue, if another key already has value with
+// the same index bytes. Check is made within transaction, so concurrent
+// transactions setting the same value conflict. Returns in.
+func (in *IndexedNamespace[KeyT, ValueT]) WithUniqueValues() *IndexedNamespace[KeyT, ValueT] {
+	in.uniqueValues = true
+	return in
+}
+
+// Sets a new value for a key and updates index
+func (in *IndexedNamespace[KeyT, ValueT]) Set(key KeyT, value ValueT) error {
+	keyb, err := in.data.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", in.data.name, err)
+	}
+
+	if in.uniqueValues {
+		err = in.checkUnique(in.indexOf(value), keyb)
+		if err != nil {
+			return fmt.Errorf("Set `%v`: %w", in.data.name, err)
+		}
+	}
+
+	err = in.deleteIndexEntry(key, keyb)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", in.data.name, err)
+	}
+
+	err = in.data.Set(key, value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", in.data.name, err)
+	}
+
+	err = in.data.txn.badgertxn.Set(in.indexKey(in.indexOf(value), keyb), keyb)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", in.data.name, err)
+	}
+
+	return nil
+}
+
+// Returns value stored under a key. Returns ok == false if key does not exist.
+func (in *IndexedNamespace[KeyT, ValueT]) Get(key KeyT) (value ValueT, ok bool, err error) {
+	return in.data.Get(key)
+}
+
+// Deletes key-value pair and its index entry. No error is returned, if passed
+// key does not exist.
+func (in *IndexedNamespace[KeyT, ValueT]) Delete(key KeyT) error {
+	keyb, err := in.data.encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", in.data.name, err)
+	}
+
+	err = in.deleteIndexEntry(key, keyb)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", in.data.name, err)
+	}
+
+	err = in.data.Delete(key)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", in.data.name, err)
+	}
+
+	return nil
+}
+
+// Iterates over all key-value pairs in key order. If viewer function returns
+// stop == true, then iteration stops.
+func (in *IndexedNamespace[KeyT, ValueT]) Iter(viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	return in.data.Iter(viewer)
+}
+
+// Iterates over key-value pairs with index bytes in range from lo inclusive to
+// hi exclusive, in index order. Nil hi means no upper bound. If viewer function
+// returns stop == true, then iteration stops.
+func (in *IndexedNamespace[KeyT, ValueT]) IterIndexRange(lo, hi []byte, viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Prefix = in.indexPrefix
+
+	it := in.data.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	for it.Seek(in.indexKey(lo, nil)); it.ValidForPrefix(in.indexPrefix); it.Next() {
+		err := in.data.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterIndexRange `%v`: %w", in.data.name, err)
+		}
+
+		item := it.Item()
+
+		keyb, err := item.ValueCopy(nil)
+		if err != nil {
+			return fmt.Errorf("IterIndexRange `%v`: %w", in.data.name, err)
+		}
+
+		indexb := item.Key()[len(in.indexPrefix) : len(item.Key())-len(keyb)]
+		if hi != nil && bytes.Compare(indexb, hi) >= 0 {
+			break
+		}
+
+		keyPtr, err := decodeGob[KeyT](keyb)
+		if err != nil {
+			return fmt.Errorf("IterIndexRange `%v`: %w", in.data.name, err)
+		}
+
+		value, ok, err := in.data.Get(*keyPtr)
+		if err != nil {
+			return fmt.Errorf("IterIndexRange `%v`: %w", in.data.name, err)
+		}
+		if !ok {
+			continue
+		}
+
+		stop, err := viewer(*keyPtr, value)
+		if err != nil {
+			return fmt.Errorf("IterIndexRange `%v`: %w", in.data.name, err)
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Removes whole index and builds it again from stored key-value pairs, for
+// example after changing indexOf or starting to use index for existing data.
+// Pairs are read within the transaction of this namespace, while index is
+// written directly to database in batches, so it is meant to be called from
+// View transaction, when no other writes to this namespace happen.
+func (in *IndexedNamespace[KeyT, ValueT]) Rebuild() error {
+	err := in.data.txn.badgerdb.DropPrefix(in.indexPrefix)
+	if err != nil {
+		return fmt.Errorf("Rebuild `%v`: %w", in.data.name, err)
+	}
+
+	wb := in.data.txn.badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	it := in.data.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := in.data.keyPrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := in.data.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("Rebuild `%v`: %w", in.data.name, err)
+		}
+
+		item := it.Item()
+		if in.data.txn.isExpired(item) {
+			continue
+		}
+
+		keyb := item.KeyCopy(nil)[len(prefix):]
+
+		err = item.Value(func(valueb []byte) error {
+			valuePtr, err := in.data.decodeValue(valueb)
+			if err != nil {
+				return err
+			}
+
+			return wb.Set(in.indexKey(in.indexOf(*valuePtr), keyb), keyb)
+		})
+		if err != nil {
+			return fmt.Errorf("Rebuild `%v`: %w", in.data.name, err)
+		}
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return fmt.Errorf("Rebuild `%v`: %w", in.data.name, err)
+	}
+
+	return nil
+}
+
+// Index entries are stored under index bytes followed by encoded key, with
+// encoded key as a value
+func (in *IndexedNamespace[KeyT, ValueT]) indexKey(indexb []byte, keyb []byte) []byte {
+	indexKey := make([]byte, 0, len(in.indexPrefix)+len(indexb)+len(keyb))
+	indexKey = append(indexKey, in.indexPrefix...)
+	indexKey = append(indexKey, indexb...)
+	return append(indexKey, keyb...)
+}
+
+// Returns ErrDuplicateValue if key other than keyb has index entry with
+// indexb
+func (in *IndexedNamespace[KeyT, ValueT]) checkUnique(indexb []byte, keyb []byte) error {
+	prefix := in.indexKey(indexb, nil)
+
+	it := in.data.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+
+		var duplicate bool
+		err := item.Value(func(otherKeyb []byte) error {
+			// Longer index bytes of another value may start with indexb
+			exact := len(item.Key()) == len(prefix)+len(otherKeyb)
+			duplicate = exact && !bytes.Equal(otherKeyb, keyb)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("checkUnique: %w", err)
+		}
+		if duplicate {
+			return fmt.Errorf("checkUnique: %w", ErrDuplicateValue)
+		}
+	}
+
+	return nil
+}
+
+func (in *IndexedNamespace[KeyT, ValueT]) deleteIndexEntry(key KeyT, keyb []byte) error {
+	oldValue, ok, err := in.data.Get(key)
+	if err != nil {
+		return fmt.Errorf("deleteIndexEntry: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	err = in.data.txn.badgertxn.Delete(in.indexKey(in.indexOf(oldValue), keyb))
+	if err != nil {
+		return fmt.Errorf("deleteIndexEntry: %w", err)
+	}
+
+	return nil
+}
+
+// Returns prefix of index entries of IndexedNamespace with passed name
+func indexPrefix(name string) []byte {
+	return addPrefixToKey(reservedKey("index\x00"+string(encodeNamespaceName(name))), nil)
+}
@@ -0,0 +1,164 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Size of chunks NamespaceLog data is split into
+const logChunkSize = 64 * 1024
+
+// Stores append-only sequence of bytes under same namespace, split into chunks,
+// so appending and reading small parts do not load the whole data
+type NamespaceLog struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing append-only bytes under same namespace. Name must
+// not be empty.
+func NewNamespaceLog(txn Txn, name string) *NamespaceLog {
+	txn.validateNamespaceName(name)
+	return &NamespaceLog{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Returns total number of stored bytes
+func (nsl *NamespaceLog) Size() (int64, error) {
+	item, err := nsl.txn.badgertxn.Get(nsl.prefix)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("Size `%v`: %w", nsl.name, err)
+	}
+
+	var size int64
+	err = item.Value(func(sizeb []byte) error {
+		sizePtr, _, err := decodePrimitive[int64](sizeb)
+		if err != nil {
+			return err
+		}
+		size = *sizePtr
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Size `%v`: %w", nsl.name, err)
+	}
+
+	return size, nil
+}
+
+// Appends data to the end and returns offset it was written at
+func (nsl *NamespaceLog) Append(data []byte) (offset int64, err error) {
+	offset, err = nsl.Size()
+	if err != nil {
+		return 0, fmt.Errorf("Append: %w", err)
+	}
+
+	size := offset
+	for len(data) > 0 {
+		chunkIndex := size / logChunkSize
+
+		chunk, err := nsl.readChunk(chunkIndex)
+		if err != nil {
+			return 0, fmt.Errorf("Append `%v`: %w", nsl.name, err)
+		}
+
+		n := logChunkSize - len(chunk)
+		if n > len(data) {
+			n = len(data)
+		}
+		chunk = append(chunk, data[:n]...)
+		data = data[n:]
+
+		err = nsl.txn.badgertxn.Set(nsl.chunkKey(chunkIndex), chunk)
+		if err != nil {
+			return 0, fmt.Errorf("Append `%v`: %w", nsl.name, err)
+		}
+
+		size += int64(n)
+	}
+
+	sizeb, _ := encodePrimitive(size)
+	err = nsl.txn.badgertxn.Set(nsl.prefix, sizeb)
+	if err != nil {
+		return 0, fmt.Errorf("Append `%v`: %w", nsl.name, err)
+	}
+
+	return offset, nil
+}
+
+// Reads length bytes starting at offset. If there are less bytes stored, reads
+// as much as available and returns io.EOF.
+func (nsl *NamespaceLog) ReadAt(offset, length int64) ([]byte, error) {
+	size, err := nsl.Size()
+	if err != nil {
+		return nil, fmt.Errorf("ReadAt: %w", err)
+	}
+
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("ReadAt `%v`: negative offset or length", nsl.name)
+	}
+
+	end := offset + length
+	var readErr error
+	if end > size {
+		end = size
+		readErr = io.EOF
+	}
+	if offset > end {
+		return nil, io.EOF
+	}
+
+	data := make([]byte, 0, end-offset)
+	for pos := offset; pos < end; {
+		chunkIndex := pos / logChunkSize
+
+		chunk, err := nsl.readChunk(chunkIndex)
+		if err != nil {
+			return nil, fmt.Errorf("ReadAt `%v`: %w", nsl.name, err)
+		}
+
+		from := pos - chunkIndex*logChunkSize
+		to := end - chunkIndex*logChunkSize
+		if to > int64(len(chunk)) {
+			to = int64(len(chunk))
+		}
+		data = append(data, chunk[from:to]...)
+
+		pos += to - from
+	}
+
+	return data, readErr
+}
+
+func (nsl *NamespaceLog) chunkKey(chunkIndex int64) []byte {
+	return joinKey(nsl.prefix, uint64Bytes(uint64(chunkIndex)))
+}
+
+func (nsl *NamespaceLog) readChunk(chunkIndex int64) ([]byte, error) {
+	item, err := nsl.txn.badgertxn.Get(nsl.chunkKey(chunkIndex))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("readChunk: %w", err)
+	}
+
+	chunk, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, fmt.Errorf("readChunk: %w", err)
+	}
+
+	return chunk, nil
+}
@@ -0,0 +1,61 @@
+package instorage
+
+import (
+	"fmt"
+)
+
+// NamespaceMultiple with map-like methods. Each entry is stored as its own key,
+// so Set and Delete do not rewrite other entries, unlike map stored as a value
+// of NamespaceSingle. MigrateSingleMap moves such map into NamespaceMap.
+type NamespaceMap[KeyT comparable, ValueT any] struct {
+	*NamespaceMultiple[KeyT, ValueT]
+}
+
+// Creates api for storing large map entry by entry under same namespace. Do
+// not use pointers as types for KeyT and ValueT. Name must not be empty.
+func NewNamespaceMap[KeyT comparable, ValueT any](txn Txn, name string) *NamespaceMap[KeyT, ValueT] {
+	return &NamespaceMap[KeyT, ValueT]{
+		NamespaceMultiple: NewNamespaceMultiple[KeyT, ValueT](txn, name),
+	}
+}
+
+// Calls fn for every entry in order of encoded keys, like range over map.
+// Iteration stops when fn returns false.
+func (nsmap *NamespaceMap[KeyT, ValueT]) Range(fn func(key KeyT, value ValueT) bool) error {
+	err := nsmap.Iter(func(key KeyT, value ValueT) (bool, error) {
+		return !fn(key, value), nil
+	})
+	if err != nil {
+		return fmt.Errorf("Range: %w", err)
+	}
+
+	return nil
+}
+
+// Moves entries of map stored as value of NamespaceSingle with name singleName
+// into NamespaceMap with name mapName and deletes the single value. Returns
+// number of moved entries. Should be called within the same transaction, which
+// first uses NamespaceMap instead of NamespaceSingle.
+func MigrateSingleMap[KeyT comparable, ValueT any](txn Txn, singleName string, mapName string) (migrated int, err error) {
+	nss := NewNamespaceSingle[map[KeyT]ValueT](txn, singleName)
+
+	m, err := nss.Get()
+	if err != nil {
+		return 0, fmt.Errorf("MigrateSingleMap: %w", err)
+	}
+
+	nsmap := NewNamespaceMap[KeyT, ValueT](txn, mapName)
+	for key, value := range m {
+		err = nsmap.Set(key, value)
+		if err != nil {
+			return 0, fmt.Errorf("MigrateSingleMap: %w", err)
+		}
+	}
+
+	err = nss.Delete()
+	if err != nil {
+		return 0, fmt.Errorf("MigrateSingleMap: %w", err)
+	}
+
+	return len(m), nil
+}
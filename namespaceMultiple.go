@@ -1,140 +1,1092 @@
 package instorage
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"strings"
+	"hash"
+	"reflect"
+	"sort"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
 // Stores multiple key-value pairs under same namespace
 type NamespaceMultiple[KeyT comparable, ValueT any] struct {
-	txn  Txn
-	name string
+	txn          Txn
+	name         string
+	prefix       []byte
+	maxValueSize int
+	// Values of basic types are stored without gob
+	primitiveValues bool
+	// ValueT takes no memory, like struct{}, so values are stored empty
+	zeroSizeValues bool
+	normalizeKey   func(key KeyT) KeyT
+	beforeStore    func(valueb []byte) ([]byte, error)
+	afterLoad      func(valueb []byte) ([]byte, error)
+	validate       func(key KeyT, value ValueT) error
+	decodeFallback func(valueb []byte) (ValueT, bool)
+	// Revision counter is incremented on writes
+	trackRevision bool
+	// Default time to live of values written by Set, zero if they do not expire
+	ttl time.Duration
+	// Set by WithCompressAbove
+	compressThreshold int
+	compressor        Compressor
+	// Number of times iteration is restarted after errors of reading values
+	iterRetries int
+	// Set by WithWriteRateLimit
+	writeLimiter     *writeLimiter
+	blockOnRateLimit bool
+	// Schema of ValueT, nil if WithoutSchemaCheck is used
+	schema []byte
+	// Set by WithMaxResults
+	maxResults int
+	// Set by WithCollisionCheck
+	checkCollisions bool
+	// Set by WithSharedTypeDescriptor
+	sharedDescriptor bool
 }
 
 // Creates api for storing multiple key-value pairs under same namespace. Do not
-// use pointers as types for KeyT and ValueT. Name must not be empty.
+// use pointers as types for KeyT and ValueT. Name must not be empty. Values of
+// zero-size types, like struct{}, take no space, which suits storing only keys.
+// Schema of ValueT is checked against schema recorded for namespace, unless
+// WithoutSchemaCheck is used.
 func NewNamespaceMultiple[KeyT comparable, ValueT any](txn Txn, name string) *NamespaceMultiple[KeyT, ValueT] {
-	if name == "" {
-		panic("name must not be empty")
+	txn.validateNamespaceName(name)
+	valueType := reflect.TypeOf((*ValueT)(nil)).Elem()
+	return &NamespaceMultiple[KeyT, ValueT]{
+		txn:            txn,
+		name:           name,
+		prefix:         txn.namespacePrefix(name),
+		zeroSizeValues: valueType.Size() == 0,
+		schema:         gobSchema(valueType),
+	}
+}
+
+// Creates api for storing multiple key-value pairs, like NewNamespaceMultiple,
+// where values written by Set expire after ttl. SetWithTTL and SetWithExpiry
+// may be used to set different expiration for single pair.
+func NewNamespaceMultipleWithTTL[KeyT comparable, ValueT any](txn Txn, name string, ttl time.Duration) *NamespaceMultiple[KeyT, ValueT] {
+	if ttl <= 0 {
+		panic("ttl must be positive")
+	}
+
+	nsm := NewNamespaceMultiple[KeyT, ValueT](txn, name)
+	nsm.ttl = ttl
+	return nsm
+}
+
+// Stores values of basic types (int64, int, int32, uint64, uint, uint32,
+// float64, bool, string and []byte) as raw bytes instead of gob, which is
+// much faster to encode and decode. Strings, byte slices and bools take less
+// space, while numbers take fixed 8 or 4 bytes, which is more than gob varints
+// of small numbers take. Values of other types are still stored with gob.
+// Must not be enabled for namespaces already containing gob encoded values.
+// Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithPrimitiveEncoding() *NamespaceMultiple[KeyT, ValueT] {
+	nsm.primitiveValues = true
+	return nsm
+}
+
+// Limits size of encoded values written by Set. Values bigger than
+// maxValueSize bytes are rejected with ErrValueTooLarge. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithMaxValueSize(maxValueSize int) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.maxValueSize = maxValueSize
+	return nsm
+}
+
+// Makes Set, SetWithExpiry, Delete and CompareAndDelete increment revision of
+// namespace returned by Revision. Concurrent transactions writing to the same
+// namespace conflict with each other, as they all update the same counter.
+// Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithRevision() *NamespaceMultiple[KeyT, ValueT] {
+	nsm.trackRevision = true
+	return nsm
+}
+
+// Returns revision of namespace, which is incremented on every write made with
+// WithRevision enabled. Returns 0 if there were no such writes, so it may be
+// polled to detect changes without scanning namespace.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Revision() (uint64, error) {
+	revision, err := nsm.readRevision()
+	if err != nil {
+		return 0, fmt.Errorf("Revision `%v`: %w", nsm.name, err)
+	}
+
+	return revision, nil
+}
+
+// Returns key storing revision of NamespaceMultiple with passed name
+func namespaceRevisionKey(name string) []byte {
+	return reservedKey("revision\x00" + string(encodeNamespaceName(name)))
+}
+
+func (nsm *NamespaceMultiple[KeyT, ValueT]) revisionKey() []byte {
+	return nsm.txn.scopedKey(namespaceRevisionKey(nsm.name))
+}
+
+func (nsm *NamespaceMultiple[KeyT, ValueT]) readRevision() (uint64, error) {
+	item, err := nsm.txn.badgertxn.Get(nsm.revisionKey())
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	var revision uint64
+	err = item.Value(func(revisionb []byte) error {
+		revisionPtr, _, err := decodePrimitive[uint64](revisionb)
+		if err != nil {
+			return err
+		}
+		revision = *revisionPtr
+		return nil
+	})
+
+	return revision, err
+}
+
+func (nsm *NamespaceMultiple[KeyT, ValueT]) bumpRevision() error {
+	if !nsm.trackRevision {
+		return nil
+	}
+
+	revision, err := nsm.readRevision()
+	if err != nil {
+		return err
+	}
+
+	return nsm.txn.badgertxn.Set(nsm.revisionKey(), uint64Bytes(revision+1))
+}
+
+// Sets function called with stored bytes, when they can not be decoded as
+// ValueT with gob, for example if value was stored with older version of
+// ValueT. If it returns ok == true, returned value is used instead of
+// reporting decoding error. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithDecodeFallback(decodeFallback func(valueb []byte) (value ValueT, ok bool)) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.decodeFallback = decodeFallback
+	return nsm
+}
+
+// Sets hooks applied to encoded values: beforeStore right before value is
+// written, and afterLoad right after it is read, before decoding. They may be
+// used to encrypt or compress values and must reverse each other. Either hook
+// may be nil. Must not be enabled for namespaces already containing values
+// stored without them. FindKeyByValue and FindDuplicates compare stored
+// bytes, so they work only if beforeStore always returns the same output for
+// the same input. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithValueHooks(beforeStore func(valueb []byte) ([]byte, error), afterLoad func(valueb []byte) ([]byte, error)) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.beforeStore = beforeStore
+	nsm.afterLoad = afterLoad
+	return nsm
+}
+
+// Compresses encoded values longer than threshold bytes with compressor and
+// stores smaller ones as is. Stored values get one byte header telling whether
+// they are compressed. Compression is applied after beforeStore hook of
+// WithValueHooks. Must not be enabled for namespaces already containing values
+// stored without it. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithCompressAbove(threshold int, compressor Compressor) *NamespaceMultiple[KeyT, ValueT] {
+	if compressor == nil {
+		panic("compressor must not be nil")
+	}
+
+	nsm.compressThreshold = threshold
+	nsm.compressor = compressor
+	return nsm
+}
+
+// Makes Iter and other iterations restart from the last pair passed to viewer
+// when badger fails to read value, for example because of transient storage
+// error, up to maxRetries times per iteration. Errors returned by viewer and
+// decoding errors are not retried. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithIterRetries(maxRetries int) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.iterRetries = maxRetries
+	return nsm
+}
+
+// Sets a new value for a key. If namespace was created with
+// NewNamespaceMultipleWithTTL, value expires after its ttl.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Set(key KeyT, value ValueT) error {
+	return nsm.set(key, nil, value)
+}
+
+// Same as Set, but uses keyb as encoded key, unless it is nil
+func (nsm *NamespaceMultiple[KeyT, ValueT]) set(key KeyT, keyb []byte, value ValueT) error {
+	if nsm.ttl > 0 {
+		err := nsm.setWithExpiry(key, keyb, value, nsm.txn.now().Add(nsm.ttl))
+		if err != nil {
+			return fmt.Errorf("Set: %w", err)
+		}
+
+		return nil
+	}
+
+	defer nsm.txn.dbopts.observeOp("Set", nsm.name, time.Now())
+
+	entry, err := nsm.newEntry(key, keyb, value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+	}
+
+	err = nsm.txn.untrackExpiry(entry.Key)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.badgertxn.SetEntry(entry)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+	}
+	err = nsm.bumpRevision()
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Sets a new value for a key, which expires at passed time. Badger tracks
+// expiration with precision of seconds. If at is not in the future, value is
+// considered expired immediately, so the key is deleted instead.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) SetWithExpiry(key KeyT, value ValueT, at time.Time) error {
+	return nsm.setWithExpiry(key, nil, value, at)
+}
+
+// Same as SetWithExpiry, but uses keyb as encoded key, unless it is nil
+func (nsm *NamespaceMultiple[KeyT, ValueT]) setWithExpiry(key KeyT, keyb []byte, value ValueT, at time.Time) error {
+	defer nsm.txn.dbopts.observeOp("SetWithExpiry", nsm.name, time.Now())
+
+	if !at.After(nsm.txn.now()) {
+		err := nsm.delete(key, keyb)
+		if err != nil {
+			return fmt.Errorf("SetWithExpiry `%v`: %w", nsm.name, err)
+		}
+
+		return nil
+	}
+
+	entry, err := nsm.newEntry(key, keyb, value)
+	if err != nil {
+		return fmt.Errorf("SetWithExpiry `%v`: %w", nsm.name, err)
+	}
+	entry.ExpiresAt = uint64(at.Unix())
+
+	err = nsm.txn.untrackExpiry(entry.Key)
+	if err != nil {
+		return fmt.Errorf("SetWithExpiry `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.badgertxn.SetEntry(entry)
+	if err != nil {
+		return fmt.Errorf("SetWithExpiry `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.trackExpiry(nsm.name, entry.Key, len(nsm.prefix), entry.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("SetWithExpiry `%v`: %w", nsm.name, err)
+	}
+	err = nsm.bumpRevision()
+	if err != nil {
+		return fmt.Errorf("SetWithExpiry `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Sets a new value for a key, which expires after ttl, overriding default ttl
+// of namespace. Works like SetWithExpiry.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) SetWithTTL(key KeyT, value ValueT, ttl time.Duration) error {
+	err := nsm.SetWithExpiry(key, value, nsm.txn.now().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("SetWithTTL: %w", err)
+	}
+
+	return nil
+}
+
+// Returns entry of key-value pair. Key is encoded, if keyb is nil.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) newEntry(key KeyT, keyb []byte, value ValueT) (*badger.Entry, error) {
+	if nsm.validate != nil {
+		err := nsm.validate(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("newEntry: %w", err)
+		}
+	}
+
+	err := nsm.takeWriteToken()
+	if err != nil {
+		return nil, fmt.Errorf("newEntry: %w", err)
+	}
+	err = nsm.checkSchema(true)
+	if err != nil {
+		return nil, fmt.Errorf("newEntry: %w", err)
+	}
+
+	if keyb == nil {
+		keyb, err = nsm.encodeKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("newEntry: %w", err)
+		}
+	}
+	err = nsm.checkKeyCollision(key, keyb)
+	if err != nil {
+		return nil, fmt.Errorf("newEntry: %w", err)
+	}
+	valueb, err := nsm.encodeValue(value, true)
+	if err != nil {
+		return nil, fmt.Errorf("newEntry: %w", err)
+	}
+	err = checkValueSize(valueb, nsm.maxValueSize)
+	if err != nil {
+		return nil, fmt.Errorf("newEntry: %w", err)
+	}
+	nsm.txn.trackWrite(len(nsm.prefix)+len(keyb), len(valueb))
+
+	return badger.NewEntry(joinKey(nsm.prefix, keyb), valueb), nil
+}
+
+// Sets function called before every write of key-value pair, including writes
+// made by Merge, Swap, CopyNamespace and ImportCSV. If it returns error,
+// nothing is written and the error is returned wrapped. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithValidator(validate func(key KeyT, value ValueT) error) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.validate = validate
+	return nsm
+}
+
+// Sets function applied to keys before they are stored or looked up, so keys
+// normalized to the same value refer to the same entry. Iteration returns
+// normalized keys. For string keys NormalizeString may be used. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithKeyNormalizer(normalize func(key KeyT) KeyT) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.normalizeKey = normalize
+	return nsm
+}
+
+func (nsm *NamespaceMultiple[KeyT, ValueT]) encodeKey(key KeyT) ([]byte, error) {
+	if nsm.normalizeKey != nil {
+		key = nsm.normalizeKey(key)
+	}
+
+	return encodeGob(key)
+}
+
+// Encodes value to be stored. If write is false, value is only encoded for
+// comparison with stored values, so nothing is written to database.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) encodeValue(value ValueT, write bool) ([]byte, error) {
+	if nsm.zeroSizeValues {
+		return nil, nil
+	}
+
+	start := time.Now()
+	var valueb []byte
+	ok := false
+	if nsm.primitiveValues {
+		valueb, ok = encodePrimitive(value)
+	}
+	if !ok && nsm.sharedDescriptor {
+		var err error
+		valueb, err = nsm.encodeWithSharedDescriptor(value, write)
+		if err != nil {
+			return nil, err
+		}
+		ok = true
+	}
+	if !ok {
+		var err error
+		valueb, err = encodeGob(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if nsm.beforeStore != nil {
+		var err error
+		valueb, err = nsm.beforeStore(valueb)
+		if err != nil {
+			return nil, fmt.Errorf("beforeStore: %w", err)
+		}
+	}
+	if nsm.compressor != nil {
+		var err error
+		valueb, err = compressAbove(valueb, nsm.compressThreshold, nsm.compressor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	nsm.txn.dbopts.observeEncode(nsm.name, len(valueb), start)
+
+	return valueb, nil
+}
+
+func (nsm *NamespaceMultiple[KeyT, ValueT]) decodeValue(valueb []byte) (*ValueT, error) {
+	if nsm.zeroSizeValues {
+		return new(ValueT), nil
+	}
+
+	start := time.Now()
+	if nsm.compressor != nil {
+		var err error
+		valueb, err = decompressAbove(valueb, nsm.compressor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if nsm.afterLoad != nil {
+		var err error
+		valueb, err = nsm.afterLoad(valueb)
+		if err != nil {
+			return nil, fmt.Errorf("afterLoad: %w", err)
+		}
+	}
+	if nsm.primitiveValues {
+		valuePtr, ok, err := decodePrimitive[ValueT](valueb)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			nsm.txn.dbopts.observeDecode(nsm.name, len(valueb), start)
+			return valuePtr, nil
+		}
+	}
+	var valuePtr *ValueT
+	var err error
+	if nsm.sharedDescriptor {
+		valuePtr, err = nsm.decodeWithSharedDescriptor(valueb)
+	} else {
+		valuePtr, err = decodeGob[ValueT](valueb)
+	}
+	if err != nil {
+		if nsm.decodeFallback != nil {
+			if value, ok := nsm.decodeFallback(valueb); ok {
+				return &value, nil
+			}
+		}
+		return nil, err
+	}
+	nsm.txn.dbopts.observeDecode(nsm.name, len(valueb), start)
+
+	return valuePtr, nil
+}
+
+// Returns value stored under a key. Returns ok == false if key does not exist.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Get(key KeyT) (value ValueT, ok bool, err error) {
+	keyb, err := nsm.encodeKey(key)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+	}
+
+	return nsm.get(keyb)
+}
+
+// Same as Get, but takes encoded key
+func (nsm *NamespaceMultiple[KeyT, ValueT]) get(keyb []byte) (value ValueT, ok bool, err error) {
+	defer nsm.txn.dbopts.observeOp("Get", nsm.name, time.Now())
+
+	err = nsm.checkSchema(false)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+	}
+
+	item, err := nsm.txn.badgertxn.Get(joinKey(nsm.prefix, keyb))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			nsm.txn.countGet(false)
+			return value, false, nil
+		}
+
+		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+	}
+	if nsm.txn.isExpired(item) {
+		nsm.txn.countGet(false)
+		return value, false, nil
+	}
+	nsm.txn.countGet(true)
+	nsm.txn.trackAccess(item.Key())
+
+	var valuePtr *ValueT
+	err = item.Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = nsm.decodeValue(valueb)
+		return err
+	})
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Deletes key-value pair. No error is returned, if passed key does not exist.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Delete(key KeyT) (err error) {
+	return nsm.delete(key, nil)
+}
+
+// Same as Delete, but uses keyb as encoded key, unless it is nil
+func (nsm *NamespaceMultiple[KeyT, ValueT]) delete(key KeyT, keyb []byte) (err error) {
+	defer nsm.txn.dbopts.observeOp("Delete", nsm.name, time.Now())
+
+	if keyb == nil {
+		keyb, err = nsm.encodeKey(key)
+		if err != nil {
+			return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+		}
+	}
+
+	storedKey := joinKey(nsm.prefix, keyb)
+
+	err = nsm.takeWriteToken()
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.untrackExpiry(storedKey)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.badgertxn.Delete(storedKey)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.forgetOriginalKey(keyb)
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.bumpRevision()
+	if err != nil {
+		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Sets value for a key to result of combine, called with currently stored value
+// (zero value if key does not exist) and incoming value.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Merge(key KeyT, incoming ValueT, combine func(existing, incoming ValueT) ValueT) error {
+	existing, _, err := nsm.Get(key)
+	if err != nil {
+		return fmt.Errorf("Merge `%v`: %w", nsm.name, err)
+	}
+
+	err = nsm.Set(key, combine(existing, incoming))
+	if err != nil {
+		return fmt.Errorf("Merge `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Swaps values stored under keyA and keyB. If only one of keys exists, its
+// value is moved under another key and it is deleted. If both keys do not
+// exist, nothing is changed.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Swap(keyA, keyB KeyT) error {
+	valueA, okA, err := nsm.Get(keyA)
+	if err != nil {
+		return fmt.Errorf("Swap `%v`: %w", nsm.name, err)
+	}
+	valueB, okB, err := nsm.Get(keyB)
+	if err != nil {
+		return fmt.Errorf("Swap `%v`: %w", nsm.name, err)
+	}
+
+	if okB {
+		err = nsm.Set(keyA, valueB)
+	} else {
+		err = nsm.Delete(keyA)
+	}
+	if err != nil {
+		return fmt.Errorf("Swap `%v`: %w", nsm.name, err)
+	}
+
+	if okA {
+		err = nsm.Set(keyB, valueA)
+	} else {
+		err = nsm.Delete(keyB)
+	}
+	if err != nil {
+		return fmt.Errorf("Swap `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Deletes key only if its stored value is equal to expected, compared by
+// encoded bytes. Returns deleted == false if key does not exist or its value
+// differs. Used within Update, transaction fails with conflict if key is
+// changed concurrently.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) CompareAndDelete(key KeyT, expected ValueT) (deleted bool, err error) {
+	keyb, err := nsm.encodeKey(key)
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	expectedb, err := nsm.encodeValue(expected, false)
+	if err != nil {
+		// No stored value may be equal to expected
+		if errors.Is(err, errTypeDescriptorNotStored) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+
+	storedKey := joinKey(nsm.prefix, keyb)
+
+	item, err := nsm.txn.badgertxn.Get(storedKey)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	if nsm.txn.isExpired(item) {
+		return false, nil
+	}
+
+	var equal bool
+	err = item.Value(func(valueb []byte) error {
+		equal = bytes.Equal(valueb, expectedb)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	if !equal {
+		return false, nil
+	}
+
+	err = nsm.takeWriteToken()
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.untrackExpiry(storedKey)
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.badgertxn.Delete(storedKey)
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.forgetOriginalKey(keyb)
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+	err = nsm.bumpRevision()
+	if err != nil {
+		return false, fmt.Errorf("CompareAndDelete `%v`: %w", nsm.name, err)
+	}
+
+	return true, nil
+}
+
+// Iterates over all key-value pairs in this namespace. If viewer function
+// returns stop == true, then iteration stops.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Iter(viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	defer nsm.txn.dbopts.observeOp("Iter", nsm.name, time.Now())
+
+	err := nsm.iterItems(nsm.keyPrefix(), nil, viewer)
+	if err != nil {
+		return fmt.Errorf("Iter `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Same as Iter, but calls progress after every `every` pairs passed to viewer
+// and once more when iteration finishes, with number of processed pairs and
+// estimated total returned by ApproxCount before iteration. Estimate may be
+// lower than number of processed pairs, including zero for namespaces not yet
+// flushed to disk, so it should be used only for displaying progress.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) IterWithProgress(every int, progress func(processed int, estimatedTotal int64), viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	defer nsm.txn.dbopts.observeOp("IterWithProgress", nsm.name, time.Now())
+
+	if every <= 0 {
+		return fmt.Errorf("IterWithProgress `%v`: %w", nsm.name, errors.New("every must be positive"))
+	}
+
+	estimatedTotal, err := nsm.ApproxCount()
+	if err != nil {
+		return fmt.Errorf("IterWithProgress `%v`: %w", nsm.name, err)
+	}
+
+	processed := 0
+	err = nsm.iterItems(nsm.keyPrefix(), nil, func(key KeyT, value ValueT) (bool, error) {
+		stop, err := viewer(key, value)
+		if err != nil {
+			return true, err
+		}
+		processed++
+		if processed%every == 0 {
+			progress(processed, estimatedTotal)
+		}
+
+		return stop, nil
+	})
+	if err != nil {
+		return fmt.Errorf("IterWithProgress `%v`: %w", nsm.name, err)
+	}
+	if processed%every != 0 || processed == 0 {
+		progress(processed, estimatedTotal)
+	}
+
+	return nil
+}
+
+// Iterates over key-value pairs, which stored keys start with prefix and for
+// which include returns true, or all of them if include is nil. Errors of
+// reading values are retried as set by WithIterRetries.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) iterItems(prefix []byte, include func(item *badger.Item) bool, viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	err := nsm.checkSchema(false)
+	if err != nil {
+		return err
+	}
+
+	var lastKey []byte
+	for retries := 0; ; retries++ {
+		var err error
+		var readFailed bool
+		lastKey, readFailed, err = nsm.iterItemsAfter(prefix, lastKey, include, viewer)
+		if err == nil || !readFailed || retries >= nsm.iterRetries {
+			return err
+		}
 	}
-	if strings.ContainsRune(name, '\x00') {
-		panic("name must not contain \\x00 symbol")
+}
+
+// Iterates like iterItems, but starting after stored key lastKey, or from the
+// start if it is nil. Returns stored key of the last pair passed to viewer, and
+// readFailed == true if error was returned by badger reading value.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) iterItemsAfter(prefix []byte, lastKey []byte, include func(item *badger.Item) bool, viewer func(key KeyT, value ValueT) (stop bool, err error)) (newLastKey []byte, readFailed bool, err error) {
+	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	seekKey := prefix
+	if lastKey != nil {
+		seekKey = addPrefixToKey(lastKey, nil)
 	}
-	return &NamespaceMultiple[KeyT, ValueT]{
-		txn:  txn,
-		name: name,
+
+	for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return lastKey, false, err
+		}
+
+		item := it.Item()
+		if nsm.txn.isExpired(item) {
+			continue
+		}
+		if include != nil && !include(item) {
+			continue
+		}
+
+		k := item.Key()
+
+		var stop bool
+		valueRead := false
+		err = item.Value(func(valueb []byte) error {
+			valueRead = true
+
+			keyPtr, err := decodeGob[KeyT](k[len(nsm.prefix):])
+			if err != nil {
+				return fmt.Errorf("decoding key rawKey=%x valueSize=%d: %w", k, len(valueb), err)
+			}
+			valuePtr, err := nsm.decodeValue(valueb)
+			if err != nil {
+				return fmt.Errorf("decoding value rawKey=%x valueSize=%d: %w", k, len(valueb), err)
+			}
+
+			stop, err = viewer(*keyPtr, *valuePtr)
+			return err
+		})
+		if err != nil {
+			return lastKey, !valueRead, err
+		}
+		if nsm.iterRetries > 0 {
+			lastKey = item.KeyCopy(nil)
+		}
+
+		if stop {
+			break
+		}
 	}
+
+	return lastKey, false, nil
 }
 
-// Sets a new value for a key
-func (nsm *NamespaceMultiple[KeyT, ValueT]) Set(key KeyT, value ValueT) error {
-	keyb, err := encodeGob(key)
+// Iterates over existing key-value pairs with passed keys in order of encoded
+// keys. Keys are sorted and looked up in one pass of iterator, which reads
+// storage sequentially and is faster than Get for large sets of keys. If
+// viewer function returns stop == true, then iteration stops.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) IterKeysIn(keys []KeyT, viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	err := nsm.checkSchema(false)
 	if err != nil {
-		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+		return fmt.Errorf("IterKeysIn `%v`: %w", nsm.name, err)
 	}
-	valueb, err := encodeGob(value)
-	if err != nil {
-		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+
+	storedKeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		keyb, err := nsm.encodeKey(key)
+		if err != nil {
+			return fmt.Errorf("IterKeysIn `%v`: %w", nsm.name, err)
+		}
+		storedKeys = append(storedKeys, joinKey(nsm.prefix, keyb))
 	}
+	sort.Slice(storedKeys, func(i, j int) bool {
+		return bytes.Compare(storedKeys[i], storedKeys[j]) < 0
+	})
 
-	err = nsm.txn.badgertxn.Set(addPrefixToKey([]byte(nsm.name), keyb), valueb)
-	if err != nil {
-		return fmt.Errorf("Set `%v`: %w", nsm.name, err)
+	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for i, storedKey := range storedKeys {
+		if i > 0 && bytes.Equal(storedKey, storedKeys[i-1]) {
+			continue
+		}
+
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterKeysIn `%v`: %w", nsm.name, err)
+		}
+
+		it.Seek(storedKey)
+		if !it.Valid() {
+			break
+		}
+		item := it.Item()
+		if !bytes.Equal(item.Key(), storedKey) || nsm.txn.isExpired(item) {
+			continue
+		}
+
+		var stop bool
+		err = item.Value(func(valueb []byte) error {
+			keyPtr, err := decodeGob[KeyT](storedKey[len(nsm.prefix):])
+			if err != nil {
+				return fmt.Errorf("decoding key rawKey=%x valueSize=%d: %w", storedKey, len(valueb), err)
+			}
+			valuePtr, err := nsm.decodeValue(valueb)
+			if err != nil {
+				return fmt.Errorf("decoding value rawKey=%x valueSize=%d: %w", storedKey, len(valueb), err)
+			}
+
+			stop, err = viewer(*keyPtr, *valuePtr)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("IterKeysIn `%v`: %w", nsm.name, err)
+		}
+
+		if stop {
+			break
+		}
 	}
 
 	return nil
 }
 
-// Returns value stored under a key. Returns ok == false if key does not exist.
-func (nsm *NamespaceMultiple[KeyT, ValueT]) Get(key KeyT) (value ValueT, ok bool, err error) {
-	keyb, err := encodeGob(key)
+// Returns key, which is read and written by every InitOnce seeding namespace
+// with passed name
+func initMarkerKey(name string) []byte {
+	return reservedKey("init\x00" + string(encodeNamespaceName(name)))
+}
+
+// Sets all pairs of seed, only if namespace has no keys, and reports whether
+// it did. Seeding reads and writes a marker key shared by all seeders of the
+// namespace, so if another transaction seeds it concurrently, one of them
+// fails on commit with badger.ErrConflict, even if their seeds have different
+// keys.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) InitOnce(seed map[KeyT]ValueT) (seeded bool, err error) {
+	empty, err := nsm.isEmpty()
 	if err != nil {
-		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+		return false, fmt.Errorf("InitOnce `%v`: %w", nsm.name, err)
+	}
+	if !empty {
+		return false, nil
 	}
 
-	item, err := nsm.txn.badgertxn.Get(addPrefixToKey([]byte(nsm.name), keyb))
+	// Marks namespace as read for conflict detection
+	markerKey := nsm.txn.scopedKey(initMarkerKey(nsm.name))
+	_, err = nsm.txn.badgertxn.Get(markerKey)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return false, fmt.Errorf("InitOnce `%v`: %w", nsm.name, err)
+	}
+	err = nsm.txn.badgertxn.Set(markerKey, nil)
 	if err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return value, false, nil
-		}
+		return false, fmt.Errorf("InitOnce `%v`: %w", nsm.name, err)
+	}
 
-		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+	for key, value := range seed {
+		err = nsm.Set(key, value)
+		if err != nil {
+			return false, fmt.Errorf("InitOnce: %w", err)
+		}
 	}
 
-	var valuePtr *ValueT
-	err = item.Value(func(valueb []byte) error {
-		var err error
-		valuePtr, err = decodeGob[ValueT](valueb)
-		return err
-	})
-	if err != nil {
-		return value, false, fmt.Errorf("Get `%v`: %w", nsm.name, err)
+	return true, nil
+}
+
+// Reports whether namespace has no keys, which are not expired
+func (nsm *NamespaceMultiple[KeyT, ValueT]) isEmpty() (bool, error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := nsm.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	prefix := nsm.keyPrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return false, err
+		}
+
+		if !nsm.txn.isExpired(it.Item()) {
+			return false, nil
+		}
 	}
 
-	return *valuePtr, true, nil
+	return true, nil
 }
 
-// Deletes key-value pair. No error is returned, if passed key does not exist.
-func (nsm *NamespaceMultiple[KeyT, ValueT]) Delete(key KeyT) (err error) {
-	keyb, err := encodeGob(key)
+// Returns prefix of idempotency markers of NamespaceMultiple with passed name
+func idempotencyPrefix(name string) []byte {
+	return reservedKey("idempotency\x00" + string(encodeNamespaceName(name)) + "\x00")
+}
+
+// Sets value for a key only if idempotencyKey was not used with this namespace
+// before, and reports whether it did. Used idempotency keys are stored as
+// markers in reserved keys of namespace, which are written in the same
+// transaction with the pair, so retried writes are applied exactly once.
+// Concurrent transactions with the same idempotency key conflict with each
+// other.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) AppendIdempotent(idempotencyKey string, key KeyT, value ValueT) (applied bool, err error) {
+	markerKey := nsm.txn.scopedKey(append(idempotencyPrefix(nsm.name), idempotencyKey...))
+
+	_, err = nsm.txn.badgertxn.Get(markerKey)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return false, fmt.Errorf("AppendIdempotent `%v`: %w", nsm.name, err)
+	}
+
+	err = nsm.Set(key, value)
 	if err != nil {
-		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+		return false, fmt.Errorf("AppendIdempotent: %w", err)
 	}
 
-	err = nsm.txn.badgertxn.Delete(addPrefixToKey([]byte(nsm.name), keyb))
+	err = nsm.txn.badgertxn.Set(markerKey, nil)
 	if err != nil {
-		return fmt.Errorf("Delete `%v`: %w", nsm.name, err)
+		return false, fmt.Errorf("AppendIdempotent `%v`: %w", nsm.name, err)
+	}
+
+	return true, nil
+}
+
+// Deletes keys of deletes and then sets pairs of upserts, so key present in
+// both ends up with upserted value. Changes are made within the transaction of
+// namespace, so if any of them fails and transaction returns the error, none
+// of them is applied. Diff must fit into badger transaction size limits.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ApplyDiff(upserts map[KeyT]ValueT, deletes []KeyT) error {
+	for _, key := range deletes {
+		err := nsm.Delete(key)
+		if err != nil {
+			return fmt.Errorf("ApplyDiff: %w", err)
+		}
+	}
+
+	for key, value := range upserts {
+		err := nsm.Set(key, value)
+		if err != nil {
+			return fmt.Errorf("ApplyDiff: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// Iterates over all key-value pairs in this namespace. If viewer function
-// returns stop == true, then iteration stops.
-func (nsm *NamespaceMultiple[KeyT, ValueT]) Iter(viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
-	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
-	defer it.Close()
+// Returns prefix of all keys stored in this namespace
+func (nsm *NamespaceMultiple[KeyT, ValueT]) keyPrefix() []byte {
+	return nsm.prefix
+}
 
-	prefix := []byte(nsm.name)
-	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-		item := it.Item()
+// Returns number of key-value pairs for which pred returns true. Both key and
+// value are decoded for every pair, even if pred uses only key.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) CountWhere(pred func(key KeyT, value ValueT) bool) (int, error) {
+	count := 0
 
-		k := item.Key()
+	err := nsm.Iter(func(key KeyT, value ValueT) (bool, error) {
+		if pred(key, value) {
+			count++
+		}
+		return false, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("CountWhere: %w", err)
+	}
 
-		var stop bool
-		err := item.Value(func(valueb []byte) error {
-			keyb := removePrefixFromKey(prefix, k)
+	return count, nil
+}
 
-			keyPtr, err := decodeGob[KeyT](keyb)
-			if err != nil {
-				return err
-			}
-			valuePtr, err := decodeGob[ValueT](valueb)
-			if err != nil {
-				return err
-			}
+// Reads all stored keys and values without decoding them, so their blocks are
+// loaded into badger cache. Call it after Open for small hot namespaces to
+// reduce latency of first reads. Cache is shared by all namespaces, so blocks
+// may be evicted later by reads of other namespaces.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Prewarm() error {
+	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
 
-			stop, err = viewer(*keyPtr, *valuePtr)
-			return err
-		})
+	prefix := nsm.keyPrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
 		if err != nil {
-			return fmt.Errorf("Iter `%v`: %w", nsm.name, err)
+			return fmt.Errorf("Prewarm `%v`: %w", nsm.name, err)
 		}
 
-		if stop {
-			break
+		err = it.Item().Value(func(valueb []byte) error {
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Prewarm `%v`: %w", nsm.name, err)
 		}
 	}
 
 	return nil
 }
 
+// Iterates over key-value pairs, which gob encoded keys start with
+// prefixBytes. This is low-level method, which depends on gob encoding of KeyT,
+// for example it may be used to find struct keys by their first field. If
+// viewer function returns stop == true, then iteration stops.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) IterKeyPrefixBytes(prefixBytes []byte, viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	err := nsm.iterItems(joinKey(nsm.prefix, prefixBytes), nil, viewer)
+	if err != nil {
+		return fmt.Errorf("IterKeyPrefixBytes `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
+// Iterates over key-value pairs written after passed version, which may be
+// obtained with Txn.ReadVersion of earlier transaction. Useful for incremental
+// replication. If viewer function returns stop == true, then iteration stops.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ChangedSince(version uint64, viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	err := nsm.iterItems(nsm.keyPrefix(), func(item *badger.Item) bool {
+		return item.Version() > version
+	}, viewer)
+	if err != nil {
+		return fmt.Errorf("ChangedSince `%v`: %w", nsm.name, err)
+	}
+
+	return nil
+}
+
 func (nsm *NamespaceMultiple[KeyT, ValueT]) FindKeyByValue(value ValueT) (key KeyT, ok bool, err error) {
-	targetvalueb, err := encodeGob(value)
+	targetvalueb, err := nsm.encodeValue(value, false)
 	if err != nil {
+		// No stored value may be equal to value
+		if errors.Is(err, errTypeDescriptorNotStored) {
+			return key, false, nil
+		}
+
 		return key, false, fmt.Errorf("FindKeyByValue `%v`: %w", nsm.name, err)
 	}
 
@@ -143,19 +1095,27 @@ func (nsm *NamespaceMultiple[KeyT, ValueT]) FindKeyByValue(value ValueT) (key Ke
 	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
 	defer it.Close()
 
-	prefix := []byte(nsm.name)
+	prefix := nsm.keyPrefix()
 	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return key, false, fmt.Errorf("FindKeyByValue `%v`: %w", nsm.name, err)
+		}
+
 		item := it.Item()
+		if nsm.txn.isExpired(item) {
+			continue
+		}
 
 		k := item.Key()
 
 		var stop bool
-		err := item.Value(func(valueb []byte) error {
+		err = item.Value(func(valueb []byte) error {
 			if string(valueb) != targetvaluebStr {
 				return nil
 			}
 
-			keyb := removePrefixFromKey(prefix, k)
+			keyb := k[len(prefix):]
 
 			keyPtr, err := decodeGob[KeyT](keyb)
 			if err != nil {
@@ -178,3 +1138,164 @@ func (nsm *NamespaceMultiple[KeyT, ValueT]) FindKeyByValue(value ValueT) (key Ke
 
 	return key, true, nil
 }
+
+// Returns approximate number of keys in this namespace without scanning it.
+// Estimation is based on key counts of on-disk tables, which contain only keys
+// of this namespace, so recent writes, not yet flushed to disk, and keys placed
+// in tables shared with other namespaces are not counted, while overwritten and
+// deleted keys, not yet compacted, may be counted multiple times.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ApproxCount() (int64, error) {
+	prefix := nsm.keyPrefix()
+
+	var count int64
+	for _, table := range nsm.txn.badgerdb.Tables() {
+		if bytes.HasPrefix(table.Left, prefix) && bytes.HasPrefix(table.Right, prefix) {
+			count += int64(table.KeyCount)
+		}
+	}
+
+	return count, nil
+}
+
+// Copies all key-value pairs from src to dst, converting values with transform.
+// Pairs for which transform returns ok == false are skipped. Namespaces may be
+// bound to different transactions, for example src to read-only one. Returns
+// number of copied pairs.
+func CopyNamespace[KeyT comparable, SrcValueT, DstValueT any](src *NamespaceMultiple[KeyT, SrcValueT], dst *NamespaceMultiple[KeyT, DstValueT], transform func(key KeyT, value SrcValueT) (newValue DstValueT, ok bool, err error)) (int, error) {
+	copied := 0
+
+	err := src.Iter(func(key KeyT, value SrcValueT) (bool, error) {
+		newValue, ok, err := transform(key, value)
+		if err != nil {
+			return true, err
+		}
+		if !ok {
+			return false, nil
+		}
+
+		err = dst.Set(key, newValue)
+		if err != nil {
+			return true, err
+		}
+
+		copied++
+
+		return false, nil
+	})
+	if err != nil {
+		return copied, fmt.Errorf("CopyNamespace `%v` to `%v`: %w", src.name, dst.name, err)
+	}
+
+	return copied, nil
+}
+
+// Adds delta to counter stored under a key (zero if key does not exist), if
+// result does not exceed maxValue. Otherwise counter is left unchanged and
+// allowed == false is returned along with its current value.
+func IncrementCapped[KeyT comparable](nsm *NamespaceMultiple[KeyT, int64], key KeyT, delta, maxValue int64) (newValue int64, allowed bool, err error) {
+	value, _, err := nsm.Get(key)
+	if err != nil {
+		return 0, false, fmt.Errorf("IncrementCapped `%v`: %w", nsm.name, err)
+	}
+
+	newValue = value + delta
+	if newValue > maxValue {
+		return value, false, nil
+	}
+
+	err = nsm.Set(key, newValue)
+	if err != nil {
+		return 0, false, fmt.Errorf("IncrementCapped `%v`: %w", nsm.name, err)
+	}
+
+	return newValue, true, nil
+}
+
+// Returns groups of keys sharing the same value. Map keys are hex encoded
+// stored value bytes, only groups with more than one key are returned.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) FindDuplicates() (map[string][]KeyT, error) {
+	groups := map[string][]KeyT{}
+
+	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := nsm.keyPrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return nil, fmt.Errorf("FindDuplicates `%v`: %w", nsm.name, err)
+		}
+
+		item := it.Item()
+		if nsm.txn.isExpired(item) {
+			continue
+		}
+
+		keyPtr, err := decodeGob[KeyT](item.Key()[len(prefix):])
+		if err != nil {
+			return nil, fmt.Errorf("FindDuplicates `%v`: %w", nsm.name, err)
+		}
+
+		err = item.Value(func(valueb []byte) error {
+			valueHex := hex.EncodeToString(valueb)
+			groups[valueHex] = append(groups[valueHex], *keyPtr)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("FindDuplicates `%v`: %w", nsm.name, err)
+		}
+	}
+
+	for valueHex, keys := range groups {
+		if len(keys) < 2 {
+			delete(groups, valueHex)
+		}
+	}
+
+	return groups, nil
+}
+
+// Returns SHA-256 hash of all stored keys and values in key order. Namespaces
+// with identical contents have identical fingerprints, regardless of database
+// and namespace name.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Fingerprint() ([32]byte, error) {
+	h := sha256.New()
+
+	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := nsm.keyPrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("Fingerprint `%v`: %w", nsm.name, err)
+		}
+
+		item := it.Item()
+		if nsm.txn.isExpired(item) {
+			continue
+		}
+
+		err = item.Value(func(valueb []byte) error {
+			writeFingerprintEntry(h, item.Key()[len(prefix):], valueb)
+			return nil
+		})
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("Fingerprint `%v`: %w", nsm.name, err)
+		}
+	}
+
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+
+	return sum, nil
+}
+
+// Entries are length prefixed, so different sequences of entries never produce
+// the same hash input
+func writeFingerprintEntry(h hash.Hash, keyb []byte, valueb []byte) {
+	h.Write(uint64Bytes(uint64(len(keyb))))
+	h.Write(keyb)
+	h.Write(uint64Bytes(uint64(len(valueb))))
+	h.Write(valueb)
+}
@@ -0,0 +1,143 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores values with priorities under same namespace, so they are popped in
+// order of priority, from the highest one. Values with equal priorities are
+// popped in order they were pushed.
+type NamespacePriorityQueue[ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing priority queue under same namespace. Do not use
+// pointer as a type for ValueT. Name must not be empty.
+func NewNamespacePriorityQueue[ValueT any](txn Txn, name string) *NamespacePriorityQueue[ValueT] {
+	txn.validateNamespaceName(name)
+	return &NamespacePriorityQueue[ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Adds value with passed priority to the queue
+func (nspq *NamespacePriorityQueue[ValueT]) Push(priority int64, value ValueT) error {
+	// Sequence number is stored under namespace prefix, right before items
+	seq, err := nextSeq(nspq.txn, nspq.prefix)
+	if err != nil {
+		return fmt.Errorf("Push `%v`: %w", nspq.name, err)
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("Push `%v`: %w", nspq.name, err)
+	}
+
+	err = nspq.txn.badgertxn.Set(nspq.itemKey(priority, seq), valueb)
+	if err != nil {
+		return fmt.Errorf("Push `%v`: %w", nspq.name, err)
+	}
+
+	return nil
+}
+
+// Removes value with the highest priority from the queue and returns it.
+// Returns ok == false if queue is empty. Concurrent transactions popping the
+// same value conflict, so only one of them is committed.
+func (nspq *NamespacePriorityQueue[ValueT]) Pop() (value ValueT, ok bool, err error) {
+	key, value, ok, err := nspq.first()
+	if err != nil {
+		return value, false, fmt.Errorf("Pop `%v`: %w", nspq.name, err)
+	}
+	if !ok {
+		return value, false, nil
+	}
+
+	err = nspq.txn.badgertxn.Delete(key)
+	if err != nil {
+		return value, false, fmt.Errorf("Pop `%v`: %w", nspq.name, err)
+	}
+
+	return value, true, nil
+}
+
+// Returns value with the highest priority without removing it. Returns
+// ok == false if queue is empty.
+func (nspq *NamespacePriorityQueue[ValueT]) Peek() (value ValueT, ok bool, err error) {
+	_, value, ok, err = nspq.first()
+	if err != nil {
+		return value, false, fmt.Errorf("Peek `%v`: %w", nspq.name, err)
+	}
+
+	return value, ok, nil
+}
+
+// Item keys consist of namespace prefix, inverted order-preserving priority,
+// so higher priorities go first, and sequence number
+func (nspq *NamespacePriorityQueue[ValueT]) itemKey(priority int64, seq uint64) []byte {
+	key := joinKey(nspq.prefix, uint64Bytes(^(uint64(priority) ^ (1 << 63))))
+	return append(key, uint64Bytes(seq)...)
+}
+
+// Increments sequence number stored under key and returns it. Sequence
+// numbers start from 1.
+func nextSeq(txn Txn, key []byte) (uint64, error) {
+	var seq uint64
+
+	item, err := txn.badgertxn.Get(key)
+	if err == nil {
+		err = item.Value(func(seqb []byte) error {
+			seqPtr, _, err := decodePrimitive[uint64](seqb)
+			if err != nil {
+				return err
+			}
+			seq = *seqPtr
+			return nil
+		})
+	}
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, err
+	}
+	seq++
+
+	err = txn.badgertxn.Set(key, uint64Bytes(seq))
+	if err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// Returns stored key and value of item with the highest priority
+func (nspq *NamespacePriorityQueue[ValueT]) first() (key []byte, value ValueT, ok bool, err error) {
+	it := nspq.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	// Sequence number is stored under namespace prefix, right before items
+	for it.Seek(joinKey(nspq.prefix, []byte{0x00})); it.ValidForPrefix(nspq.prefix); it.Next() {
+		item := it.Item()
+		if nspq.txn.isExpired(item) {
+			continue
+		}
+
+		valueb, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, value, false, err
+		}
+		valuePtr, err := decodeGob[ValueT](valueb)
+		if err != nil {
+			return nil, value, false, err
+		}
+
+		return item.KeyCopy(nil), *valuePtr, true, nil
+	}
+
+	return nil, value, false, nil
+}
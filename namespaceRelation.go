@@ -0,0 +1,174 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Directions of NamespaceRelation keys, stored right after namespace prefix
+const (
+	relationLeftToRight byte = 0x01
+	relationRightToLeft byte = 0x02
+)
+
+// Stores many-to-many relation between left and right values. Every link is
+// stored twice, as left to right and right to left key, so both sides of
+// relation are read with a prefix scan. Keys are stored as direction byte,
+// gob encoded side, \x00 separator and gob encoded other side, values are
+// empty.
+type NamespaceRelation[LeftT comparable, RightT comparable] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing relation under same namespace. Do not use pointers
+// as types for LeftT and RightT. Name must not be empty.
+func NewNamespaceRelation[LeftT comparable, RightT comparable](txn Txn, name string) *NamespaceRelation[LeftT, RightT] {
+	txn.validateNamespaceName(name)
+	return &NamespaceRelation[LeftT, RightT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Links left and right values. No error is returned, if they are linked
+// already.
+func (nsr *NamespaceRelation[LeftT, RightT]) Link(left LeftT, right RightT) error {
+	leftKey, rightKey, err := nsr.keys(left, right)
+	if err != nil {
+		return fmt.Errorf("Link `%v`: %w", nsr.name, err)
+	}
+
+	for _, key := range [][]byte{leftKey, rightKey} {
+		err = nsr.txn.badgertxn.Set(key, nil)
+		if err != nil {
+			return fmt.Errorf("Link `%v`: %w", nsr.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Removes link between left and right values. No error is returned, if they
+// are not linked.
+func (nsr *NamespaceRelation[LeftT, RightT]) Unlink(left LeftT, right RightT) error {
+	leftKey, rightKey, err := nsr.keys(left, right)
+	if err != nil {
+		return fmt.Errorf("Unlink `%v`: %w", nsr.name, err)
+	}
+
+	for _, key := range [][]byte{leftKey, rightKey} {
+		err = nsr.txn.badgertxn.Delete(key)
+		if err != nil {
+			return fmt.Errorf("Unlink `%v`: %w", nsr.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Reports whether left and right values are linked
+func (nsr *NamespaceRelation[LeftT, RightT]) Linked(left LeftT, right RightT) (bool, error) {
+	leftKey, _, err := nsr.keys(left, right)
+	if err != nil {
+		return false, fmt.Errorf("Linked `%v`: %w", nsr.name, err)
+	}
+
+	_, err = nsr.txn.badgertxn.Get(leftKey)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Linked `%v`: %w", nsr.name, err)
+	}
+
+	return true, nil
+}
+
+// Returns right values linked with left value, in order of their encodings
+func (nsr *NamespaceRelation[LeftT, RightT]) RightsOf(left LeftT) ([]RightT, error) {
+	sidePrefix, err := relationSidePrefix(nsr.prefix, relationLeftToRight, left)
+	if err != nil {
+		return nil, fmt.Errorf("RightsOf `%v`: %w", nsr.name, err)
+	}
+
+	rights, err := relationOtherSides[RightT](nsr.txn, sidePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("RightsOf `%v`: %w", nsr.name, err)
+	}
+
+	return rights, nil
+}
+
+// Returns left values linked with right value, in order of their encodings
+func (nsr *NamespaceRelation[LeftT, RightT]) LeftsOf(right RightT) ([]LeftT, error) {
+	sidePrefix, err := relationSidePrefix(nsr.prefix, relationRightToLeft, right)
+	if err != nil {
+		return nil, fmt.Errorf("LeftsOf `%v`: %w", nsr.name, err)
+	}
+
+	lefts, err := relationOtherSides[LeftT](nsr.txn, sidePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("LeftsOf `%v`: %w", nsr.name, err)
+	}
+
+	return lefts, nil
+}
+
+// Returns left to right and right to left keys of link
+func (nsr *NamespaceRelation[LeftT, RightT]) keys(left LeftT, right RightT) (leftKey []byte, rightKey []byte, err error) {
+	leftPrefix, err := relationSidePrefix(nsr.prefix, relationLeftToRight, left)
+	if err != nil {
+		return nil, nil, err
+	}
+	rightPrefix, err := relationSidePrefix(nsr.prefix, relationRightToLeft, right)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Side prefixes end with encoded sides and \x00 separator
+	leftb := leftPrefix[len(nsr.prefix)+1 : len(leftPrefix)-1]
+	rightb := rightPrefix[len(nsr.prefix)+1 : len(rightPrefix)-1]
+
+	return joinKey(leftPrefix, rightb), joinKey(rightPrefix, leftb), nil
+}
+
+// Returns prefix of keys of links of side in passed direction: namespace
+// prefix, direction byte, encoded side and \x00 separator
+func relationSidePrefix(prefix []byte, direction byte, side any) ([]byte, error) {
+	sideb, err := encodeGob(side)
+	if err != nil {
+		return nil, err
+	}
+
+	return addPrefixToKey(joinKey(prefix, append([]byte{direction}, sideb...)), nil), nil
+}
+
+// Decodes other sides of links stored with passed side prefix
+func relationOtherSides[OtherT any](txn Txn, sidePrefix []byte) ([]OtherT, error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var others []OtherT
+	for it.Seek(sidePrefix); it.ValidForPrefix(sidePrefix); it.Next() {
+		err := txn.checkContext()
+		if err != nil {
+			return nil, err
+		}
+
+		otherPtr, err := decodeGob[OtherT](it.Item().Key()[len(sidePrefix):])
+		if err != nil {
+			return nil, err
+		}
+		others = append(others, *otherPtr)
+	}
+
+	return others, nil
+}
@@ -0,0 +1,90 @@
+package instorage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Keys of NamespaceRotating values
+const (
+	rotatingCurrent uint8 = iota
+	rotatingPrevious
+)
+
+// Stores current value and previous one, which stays readable for a grace
+// period after rotation, for example signing keys, so tokens signed with the
+// previous key still validate.
+type NamespaceRotating[ValueT any] struct {
+	data *NamespaceMultiple[uint8, ValueT]
+}
+
+// Creates api for storing rotated value under same namespace. Do not use
+// pointer as a type for ValueT. Name must not be empty.
+func NewNamespaceRotating[ValueT any](txn Txn, name string) *NamespaceRotating[ValueT] {
+	return &NamespaceRotating[ValueT]{
+		data: NewNamespaceMultiple[uint8, ValueT](txn, name),
+	}
+}
+
+// Makes newValue current. Current value, if any, becomes previous and expires
+// after gracePeriod, replacing previous value. If gracePeriod is not positive,
+// current value is discarded instead.
+func (nsr *NamespaceRotating[ValueT]) Rotate(newValue ValueT, gracePeriod time.Duration) error {
+	current, ok, err := nsr.data.Get(rotatingCurrent)
+	if err != nil {
+		return fmt.Errorf("Rotate: %w", err)
+	}
+
+	if ok && gracePeriod > 0 {
+		err = nsr.data.SetWithTTL(rotatingPrevious, current, gracePeriod)
+	} else {
+		err = nsr.data.Delete(rotatingPrevious)
+	}
+	if err != nil {
+		return fmt.Errorf("Rotate: %w", err)
+	}
+
+	err = nsr.data.Set(rotatingCurrent, newValue)
+	if err != nil {
+		return fmt.Errorf("Rotate: %w", err)
+	}
+
+	return nil
+}
+
+// Returns current value. Returns ok == false if no value was rotated in yet.
+func (nsr *NamespaceRotating[ValueT]) Current() (value ValueT, ok bool, err error) {
+	value, ok, err = nsr.data.Get(rotatingCurrent)
+	if err != nil {
+		return value, false, fmt.Errorf("Current: %w", err)
+	}
+
+	return value, ok, nil
+}
+
+// Returns previous value. Returns ok == false if there is no previous value
+// or its grace period has passed.
+func (nsr *NamespaceRotating[ValueT]) Previous() (value ValueT, ok bool, err error) {
+	value, ok, err = nsr.data.Get(rotatingPrevious)
+	if err != nil {
+		return value, false, fmt.Errorf("Previous: %w", err)
+	}
+
+	return value, ok, nil
+}
+
+// Reports whether matches function returns true for current value or for
+// previous value within its grace period. Current value is checked first.
+func (nsr *NamespaceRotating[ValueT]) Verify(matches func(value ValueT) bool) (bool, error) {
+	for _, key := range []uint8{rotatingCurrent, rotatingPrevious} {
+		value, ok, err := nsr.data.Get(key)
+		if err != nil {
+			return false, fmt.Errorf("Verify: %w", err)
+		}
+		if ok && matches(value) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
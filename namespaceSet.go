@@ -0,0 +1,108 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores set of string members under same namespace. Members are stored as
+// raw bytes, so they are ordered lexicographically and can be listed by prefix.
+type NamespaceSet struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing set of string members under same namespace. Name
+// must not be empty.
+func NewNamespaceSet(txn Txn, name string) *NamespaceSet {
+	txn.validateNamespaceName(name)
+	return &NamespaceSet{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Adds member to the set
+func (nsset *NamespaceSet) Add(member string) error {
+	err := nsset.txn.badgertxn.Set(joinKey(nsset.prefix, []byte(member)), nil)
+	if err != nil {
+		return fmt.Errorf("Add `%v`: %w", nsset.name, err)
+	}
+
+	return nil
+}
+
+// Reports whether member is in the set
+func (nsset *NamespaceSet) Has(member string) (bool, error) {
+	item, err := nsset.txn.badgertxn.Get(joinKey(nsset.prefix, []byte(member)))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Has `%v`: %w", nsset.name, err)
+	}
+
+	return !nsset.txn.isExpired(item), nil
+}
+
+// Removes member from the set. No error is returned, if member is not in the
+// set.
+func (nsset *NamespaceSet) Remove(member string) error {
+	err := nsset.txn.badgertxn.Delete(joinKey(nsset.prefix, []byte(member)))
+	if err != nil {
+		return fmt.Errorf("Remove `%v`: %w", nsset.name, err)
+	}
+
+	return nil
+}
+
+// Iterates over all members in lexicographical order. If viewer function
+// returns stop == true, then iteration stops.
+func (nsset *NamespaceSet) Iter(viewer func(member string) (stop bool, err error)) error {
+	err := nsset.IterPrefix("", viewer)
+	if err != nil {
+		return fmt.Errorf("Iter: %w", err)
+	}
+
+	return nil
+}
+
+// Iterates over members starting with prefix in lexicographical order. If
+// viewer function returns stop == true, then iteration stops.
+func (nsset *NamespaceSet) IterPrefix(prefix string, viewer func(member string) (stop bool, err error)) error {
+	seekKey := joinKey(nsset.prefix, []byte(prefix))
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+
+	it := nsset.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	for it.Seek(seekKey); it.ValidForPrefix(seekKey); it.Next() {
+		err := nsset.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterPrefix `%v`: %w", nsset.name, err)
+		}
+
+		item := it.Item()
+		if nsset.txn.isExpired(item) {
+			continue
+		}
+
+		stop, err := viewer(string(item.Key()[len(nsset.prefix):]))
+		if err != nil {
+			return fmt.Errorf("IterPrefix `%v`: %w", nsset.name, err)
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package instorage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Stores values encrypted with AES-GCM using encryption key of every entry,
+// which is returned by keyFor and stored elsewhere, so deleting encryption key
+// makes values encrypted with it unrecoverable (crypto-shredding). Encoded key
+// of entry is authenticated with its value, so values can not be moved
+// between keys.
+type NamespaceShreddable[KeyT comparable, ValueT any] struct {
+	data   *NamespaceMultiple[KeyT, []byte]
+	keyFor func(key KeyT) ([]byte, error)
+}
+
+// Creates api for storing encrypted key-value pairs under same namespace.
+// keyFor must return 16, 24 or 32 bytes encryption key of entry, for example
+// per-user key looked up by user ID in key. Do not use pointers as types for
+// KeyT and ValueT. Name must not be empty.
+func NewNamespaceShreddable[KeyT comparable, ValueT any](txn Txn, name string, keyFor func(key KeyT) ([]byte, error)) *NamespaceShreddable[KeyT, ValueT] {
+	if keyFor == nil {
+		panic("keyFor must not be nil")
+	}
+
+	return &NamespaceShreddable[KeyT, ValueT]{
+		data:   NewNamespaceMultiple[KeyT, []byte](txn, name),
+		keyFor: keyFor,
+	}
+}
+
+// Encrypts value with encryption key of key and sets it
+func (nsh *NamespaceShreddable[KeyT, ValueT]) Set(key KeyT, value ValueT) error {
+	aead, keyb, err := nsh.cipherFor(key)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsh.data.name, err)
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsh.data.name, err)
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(valueb)+aead.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nsh.data.name, err)
+	}
+
+	err = nsh.data.Set(key, aead.Seal(nonce, nonce, valueb, keyb))
+	if err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+
+	return nil
+}
+
+// Returns decrypted value stored under a key. Returns ok == false if key does
+// not exist. Returns error of keyFor, if encryption key can not be obtained,
+// for example after it was deleted.
+func (nsh *NamespaceShreddable[KeyT, ValueT]) Get(key KeyT) (value ValueT, ok bool, err error) {
+	sealed, ok, err := nsh.data.Get(key)
+	if err != nil {
+		return value, false, fmt.Errorf("Get: %w", err)
+	}
+	if !ok {
+		return value, false, nil
+	}
+
+	aead, keyb, err := nsh.cipherFor(key)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsh.data.name, err)
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsh.data.name, errors.New("encrypted value is too short"))
+	}
+	valueb, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], keyb)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsh.data.name, err)
+	}
+
+	valuePtr, err := decodeGob[ValueT](valueb)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nsh.data.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Deletes value stored under a key. Encryption key is not needed.
+func (nsh *NamespaceShreddable[KeyT, ValueT]) Delete(key KeyT) error {
+	err := nsh.data.Delete(key)
+	if err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	return nil
+}
+
+// Returns cipher with encryption key of key and encoded key used as
+// additional authenticated data
+func (nsh *NamespaceShreddable[KeyT, ValueT]) cipherFor(key KeyT) (cipher.AEAD, []byte, error) {
+	encryptionKey, err := nsh.keyFor(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("keyFor: %w", err)
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyb, err := nsh.data.encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead, keyb, nil
+}
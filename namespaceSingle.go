@@ -3,62 +3,186 @@ package instorage
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
 // Basic key-value pair for database
 type NamespaceSingle[ValueT any] struct {
-	txn  Txn
-	name string
+	txn          Txn
+	name         string
+	key          []byte
+	maxValueSize int
+	// Values of basic types are stored without gob
+	primitiveValues bool
+	defaultValue    ValueT
+	beforeStore     func(valueb []byte) ([]byte, error)
+	afterLoad       func(valueb []byte) ([]byte, error)
+	decodeFallback  func(valueb []byte) (ValueT, bool)
 }
 
 // Creates api for storing single key-value pair with specified name. Do not use
 // pointer as a type for ValueT. Name must not be empty.
 func NewNamespaceSingle[ValueT any](txn Txn, name string) *NamespaceSingle[ValueT] {
-	if name == "" {
-		panic("name must not be empty")
-	}
-	if strings.ContainsRune(name, '\x00') {
-		panic("name must not contain \\x00 symbol")
-	}
+	txn.validateNamespaceName(name)
 	return &NamespaceSingle[ValueT]{
 		txn:  txn,
 		name: name,
+		key:  txn.singleKey(name),
 	}
 }
 
+// Same as NewNamespaceSingle, but Get returns defaultValue instead of zero value
+// when no value is stored.
+func NewNamespaceSingleWithDefault[ValueT any](txn Txn, name string, defaultValue ValueT) *NamespaceSingle[ValueT] {
+	nss := NewNamespaceSingle[ValueT](txn, name)
+	nss.defaultValue = defaultValue
+	return nss
+}
+
+// Stores values of basic types (int64, int, int32, uint64, uint, uint32,
+// float64, bool, string and []byte) as raw bytes instead of gob, which is
+// much faster to encode and decode. Strings, byte slices and bools take less
+// space, while numbers take fixed 8 or 4 bytes, which is more than gob varints
+// of small numbers take. Values of other types are still stored with gob.
+// Must not be enabled for namespaces already containing gob encoded values.
+// Returns nss.
+func (nss *NamespaceSingle[ValueT]) WithPrimitiveEncoding() *NamespaceSingle[ValueT] {
+	nss.primitiveValues = true
+	return nss
+}
+
+// Limits size of encoded value written by Set. Values bigger than
+// maxValueSize bytes are rejected with ErrValueTooLarge. Returns nss.
+func (nss *NamespaceSingle[ValueT]) WithMaxValueSize(maxValueSize int) *NamespaceSingle[ValueT] {
+	nss.maxValueSize = maxValueSize
+	return nss
+}
+
+// Sets function called with stored bytes, when they can not be decoded as
+// ValueT with gob, for example if value was stored with older version of
+// ValueT. If it returns ok == true, returned value is used instead of
+// reporting decoding error. Returns nss.
+func (nss *NamespaceSingle[ValueT]) WithDecodeFallback(decodeFallback func(valueb []byte) (value ValueT, ok bool)) *NamespaceSingle[ValueT] {
+	nss.decodeFallback = decodeFallback
+	return nss
+}
+
+// Sets hooks applied to encoded value: beforeStore right before value is
+// written, and afterLoad right after it is read, before decoding. They may be
+// used to encrypt or compress value and must reverse each other. Either hook
+// may be nil. Must not be enabled for namespaces already containing value
+// stored without them. Returns nss.
+func (nss *NamespaceSingle[ValueT]) WithValueHooks(beforeStore func(valueb []byte) ([]byte, error), afterLoad func(valueb []byte) ([]byte, error)) *NamespaceSingle[ValueT] {
+	nss.beforeStore = beforeStore
+	nss.afterLoad = afterLoad
+	return nss
+}
+
 // Sets new value
 func (nss *NamespaceSingle[ValueT]) Set(value ValueT) error {
-	valueb, err := encodeGob(value)
+	defer nss.txn.dbopts.observeOp("Set", nss.name, time.Now())
+
+	valueb, err := nss.encodeValue(value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nss.name, err)
+	}
+	err = checkValueSize(valueb, nss.maxValueSize)
 	if err != nil {
 		return fmt.Errorf("Set `%v`: %w", nss.name, err)
 	}
-	err = nss.txn.badgertxn.Set([]byte(nss.name), valueb)
+	err = nss.txn.badgertxn.Set(nss.key, valueb)
 	if err != nil {
 		return fmt.Errorf("Set `%v`: %w", nss.name, err)
 	}
+	nss.txn.trackWrite(len(nss.key), len(valueb))
 
 	return nil
 }
 
+func (nss *NamespaceSingle[ValueT]) encodeValue(value ValueT) ([]byte, error) {
+	start := time.Now()
+	var valueb []byte
+	ok := false
+	if nss.primitiveValues {
+		valueb, ok = encodePrimitive(value)
+	}
+	if !ok {
+		var err error
+		valueb, err = encodeGob(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if nss.beforeStore != nil {
+		var err error
+		valueb, err = nss.beforeStore(valueb)
+		if err != nil {
+			return nil, fmt.Errorf("beforeStore: %w", err)
+		}
+	}
+	nss.txn.dbopts.observeEncode(nss.name, len(valueb), start)
+
+	return valueb, nil
+}
+
+func (nss *NamespaceSingle[ValueT]) decodeValue(valueb []byte) (*ValueT, error) {
+	start := time.Now()
+	if nss.afterLoad != nil {
+		var err error
+		valueb, err = nss.afterLoad(valueb)
+		if err != nil {
+			return nil, fmt.Errorf("afterLoad: %w", err)
+		}
+	}
+	if nss.primitiveValues {
+		valuePtr, ok, err := decodePrimitive[ValueT](valueb)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			nss.txn.dbopts.observeDecode(nss.name, len(valueb), start)
+			return valuePtr, nil
+		}
+	}
+	valuePtr, err := decodeGob[ValueT](valueb)
+	if err != nil {
+		if nss.decodeFallback != nil {
+			if value, ok := nss.decodeFallback(valueb); ok {
+				return &value, nil
+			}
+		}
+		return nil, err
+	}
+	nss.txn.dbopts.observeDecode(nss.name, len(valueb), start)
+
+	return valuePtr, nil
+}
+
 // Returns saved value. If no value stored at the moment, returns default value
-// for specified type in NewNamespaceSingle
+// for specified type in NewNamespaceSingle, or value passed to
+// NewNamespaceSingleWithDefault
 func (nss *NamespaceSingle[ValueT]) Get() (value ValueT, err error) {
-	item, err := nss.txn.badgertxn.Get([]byte(nss.name))
+	defer nss.txn.dbopts.observeOp("Get", nss.name, time.Now())
+
+	item, err := nss.txn.badgertxn.Get(nss.key)
 	if err != nil {
 		if errors.Is(err, badger.ErrKeyNotFound) {
-			return value, nil
+			return nss.defaultValue, nil
 		}
 
 		return value, fmt.Errorf("Get `%v`: %w", nss.name, err)
 	}
+	if nss.txn.isExpired(item) {
+		return nss.defaultValue, nil
+	}
+	nss.txn.trackAccess(item.Key())
 
 	var valuePtr *ValueT
 	err = item.Value(func(valueb []byte) error {
 		var err error
-		valuePtr, err = decodeGob[ValueT](valueb)
+		valuePtr, err = nss.decodeValue(valueb)
 		return err
 	})
 	if err != nil {
@@ -71,7 +195,9 @@ func (nss *NamespaceSingle[ValueT]) Get() (value ValueT, err error) {
 // Delete key-value pair from database. No error is returned if this key-value
 // pair does not exist.
 func (nss *NamespaceSingle[ValueT]) Delete() (err error) {
-	err = nss.txn.badgertxn.Delete([]byte(nss.name))
+	defer nss.txn.dbopts.observeOp("Delete", nss.name, time.Now())
+
+	err = nss.txn.badgertxn.Delete(nss.key)
 	if err != nil {
 		if errors.Is(err, badger.ErrKeyNotFound) {
 			return nil
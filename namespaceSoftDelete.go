@@ -0,0 +1,171 @@
+package instorage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stores key-value pairs, which are soft-deleted: SoftDelete marks pair as
+// deleted keeping its value, so it may be restored. Get and Iter skip
+// soft-deleted pairs, unless IncludeDeleted is set. PurgeDeleted removes them
+// physically.
+type NamespaceSoftDelete[KeyT comparable, ValueT any] struct {
+	data           *NamespaceMultiple[KeyT, softDeleteEntry[ValueT]]
+	includeDeleted bool
+}
+
+// Stored value of NamespaceSoftDelete. DeletedAt is zero, if value is not
+// deleted.
+type softDeleteEntry[ValueT any] struct {
+	Value     ValueT
+	DeletedAt time.Time
+}
+
+// Creates api for storing soft-deletable key-value pairs under same namespace.
+// Do not use pointers as types for KeyT and ValueT. Name must not be empty.
+func NewNamespaceSoftDelete[KeyT comparable, ValueT any](txn Txn, name string) *NamespaceSoftDelete[KeyT, ValueT] {
+	return &NamespaceSoftDelete[KeyT, ValueT]{
+		data: NewNamespaceMultiple[KeyT, softDeleteEntry[ValueT]](txn, name),
+	}
+}
+
+// Makes Get and Iter return soft-deleted pairs too. Returns nsd.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) IncludeDeleted() *NamespaceSoftDelete[KeyT, ValueT] {
+	nsd.includeDeleted = true
+	return nsd
+}
+
+// Sets value under a key. Soft-deleted pair is restored with the new value.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) Set(key KeyT, value ValueT) error {
+	err := nsd.data.Set(key, softDeleteEntry[ValueT]{Value: value})
+	if err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+
+	return nil
+}
+
+// Returns value stored under a key. Returns ok == false if key does not exist
+// or it is soft-deleted and IncludeDeleted is not set.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) Get(key KeyT) (value ValueT, ok bool, err error) {
+	entry, ok, err := nsd.data.Get(key)
+	if err != nil {
+		return value, false, fmt.Errorf("Get: %w", err)
+	}
+	if !ok || (!entry.DeletedAt.IsZero() && !nsd.includeDeleted) {
+		return value, false, nil
+	}
+
+	return entry.Value, true, nil
+}
+
+// Returns time when pair was soft-deleted. Returns deleted == false if key
+// does not exist or it is not soft-deleted.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) DeletedAt(key KeyT) (at time.Time, deleted bool, err error) {
+	entry, ok, err := nsd.data.Get(key)
+	if err != nil {
+		return at, false, fmt.Errorf("DeletedAt: %w", err)
+	}
+	if !ok || entry.DeletedAt.IsZero() {
+		return at, false, nil
+	}
+
+	return entry.DeletedAt, true, nil
+}
+
+// Marks pair as deleted, keeping its value. Returns ok == false if key does
+// not exist. Pair already soft-deleted keeps its deletion time.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) SoftDelete(key KeyT) (ok bool, err error) {
+	entry, ok, err := nsd.data.Get(key)
+	if err != nil {
+		return false, fmt.Errorf("SoftDelete: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+	if !entry.DeletedAt.IsZero() {
+		return true, nil
+	}
+
+	entry.DeletedAt = nsd.data.txn.now()
+	err = nsd.data.Set(key, entry)
+	if err != nil {
+		return false, fmt.Errorf("SoftDelete: %w", err)
+	}
+
+	return true, nil
+}
+
+// Removes deletion mark of soft-deleted pair. Returns ok == false if key does
+// not exist. No error is returned, if pair is not soft-deleted.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) Restore(key KeyT) (ok bool, err error) {
+	entry, ok, err := nsd.data.Get(key)
+	if err != nil {
+		return false, fmt.Errorf("Restore: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+	if entry.DeletedAt.IsZero() {
+		return true, nil
+	}
+
+	entry.DeletedAt = time.Time{}
+	err = nsd.data.Set(key, entry)
+	if err != nil {
+		return false, fmt.Errorf("Restore: %w", err)
+	}
+
+	return true, nil
+}
+
+// Deletes pair physically, whether it is soft-deleted or not
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) Delete(key KeyT) error {
+	err := nsd.data.Delete(key)
+	if err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	return nil
+}
+
+// Iterates over key-value pairs, skipping soft-deleted ones, unless
+// IncludeDeleted is set. If viewer function returns stop == true, then
+// iteration stops.
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) Iter(viewer func(key KeyT, value ValueT) (stop bool, err error)) error {
+	err := nsd.data.Iter(func(key KeyT, entry softDeleteEntry[ValueT]) (bool, error) {
+		if !entry.DeletedAt.IsZero() && !nsd.includeDeleted {
+			return false, nil
+		}
+
+		return viewer(key, entry.Value)
+	})
+	if err != nil {
+		return fmt.Errorf("Iter: %w", err)
+	}
+
+	return nil
+}
+
+// Deletes all soft-deleted pairs physically and returns their number
+func (nsd *NamespaceSoftDelete[KeyT, ValueT]) PurgeDeleted() (int, error) {
+	var keys []KeyT
+	err := nsd.data.Iter(func(key KeyT, entry softDeleteEntry[ValueT]) (bool, error) {
+		if !entry.DeletedAt.IsZero() {
+			keys = append(keys, key)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("PurgeDeleted: %w", err)
+	}
+
+	for _, key := range keys {
+		err = nsd.data.Delete(key)
+		if err != nil {
+			return 0, fmt.Errorf("PurgeDeleted: %w", err)
+		}
+	}
+
+	return len(keys), nil
+}
@@ -0,0 +1,105 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores values ordered by time. Keys are stored as big-endian nanoseconds
+// with inverted sign bit, so byte order of keys matches time order.
+type NamespaceTimeSeries[ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing values ordered by time under same namespace. Do not
+// use pointer as a type for ValueT. Name must not be empty.
+func NewNamespaceTimeSeries[ValueT any](txn Txn, name string) *NamespaceTimeSeries[ValueT] {
+	txn.validateNamespaceName(name)
+	return &NamespaceTimeSeries[ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Sets value at passed time with precision of nanoseconds. Value previously
+// set at the same time is replaced.
+func (nst *NamespaceTimeSeries[ValueT]) Set(at time.Time, value ValueT) error {
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nst.name, err)
+	}
+
+	err = nst.txn.badgertxn.Set(joinKey(nst.prefix, scoreBytes(at.UnixNano())), valueb)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nst.name, err)
+	}
+
+	return nil
+}
+
+// Iterates over values in time order, grouped into buckets of passed duration,
+// in a single pass. viewer is called once per bucket containing values, with
+// start of bucket and its values in time order. Buckets start at multiples of
+// bucket since zero time, as returned by time.Time.Truncate. If viewer
+// function returns stop == true, then iteration stops.
+func (nst *NamespaceTimeSeries[ValueT]) IterBuckets(bucket time.Duration, viewer func(bucketStart time.Time, values []ValueT) (stop bool, err error)) error {
+	if bucket <= 0 {
+		panic("bucket must be positive")
+	}
+
+	it := nst.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var bucketStart time.Time
+	var values []ValueT
+	for it.Seek(nst.prefix); it.ValidForPrefix(nst.prefix); it.Next() {
+		err := nst.txn.checkContext()
+		if err != nil {
+			return fmt.Errorf("IterBuckets `%v`: %w", nst.name, err)
+		}
+
+		item := it.Item()
+		nanos := int64(binary.BigEndian.Uint64(item.Key()[len(nst.prefix):]) ^ (1 << 63))
+		start := time.Unix(0, nanos).Truncate(bucket)
+
+		if len(values) > 0 && !start.Equal(bucketStart) {
+			stop, err := viewer(bucketStart, values)
+			if err != nil {
+				return fmt.Errorf("IterBuckets `%v`: %w", nst.name, err)
+			}
+			if stop {
+				return nil
+			}
+			values = nil
+		}
+		bucketStart = start
+
+		err = item.Value(func(valueb []byte) error {
+			valuePtr, err := decodeGob[ValueT](valueb)
+			if err != nil {
+				return err
+			}
+
+			values = append(values, *valuePtr)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("IterBuckets `%v`: %w", nst.name, err)
+		}
+	}
+
+	if len(values) > 0 {
+		_, err := viewer(bucketStart, values)
+		if err != nil {
+			return fmt.Errorf("IterBuckets `%v`: %w", nst.name, err)
+		}
+	}
+
+	return nil
+}
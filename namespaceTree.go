@@ -0,0 +1,201 @@
+package instorage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores values under slash-delimited paths like "a/b/c", so direct children
+// of a path may be listed and whole subtree may be deleted with a prefix scan.
+// Path segments are stored separated by \x00, so subtree of a path is stored
+// right after it.
+type NamespaceTree[ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+}
+
+// Creates api for storing values in a tree of paths under same namespace. Do
+// not use pointer as a type for ValueT. Name must not be empty.
+func NewNamespaceTree[ValueT any](txn Txn, name string) *NamespaceTree[ValueT] {
+	txn.validateNamespaceName(name)
+	return &NamespaceTree[ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+	}
+}
+
+// Sets value under path. Leading and trailing slashes of path are ignored.
+// Path must not be empty and its segments must not be empty or contain \x00
+// symbol. Parent paths do not need to have values.
+func (nst *NamespaceTree[ValueT]) Set(path string, value ValueT) error {
+	key, err := nst.pathKey(path)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nst.name, err)
+	}
+	if len(key) == len(nst.prefix) {
+		return fmt.Errorf("Set `%v`: %w", nst.name, errors.New("path must not be empty"))
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nst.name, err)
+	}
+
+	err = nst.txn.badgertxn.Set(key, valueb)
+	if err != nil {
+		return fmt.Errorf("Set `%v`: %w", nst.name, err)
+	}
+
+	return nil
+}
+
+// Returns value stored under path. Returns ok == false if path has no value.
+func (nst *NamespaceTree[ValueT]) Get(path string) (value ValueT, ok bool, err error) {
+	key, err := nst.pathKey(path)
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nst.name, err)
+	}
+	if len(key) == len(nst.prefix) {
+		return value, false, nil
+	}
+
+	item, err := nst.txn.badgertxn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return value, false, nil
+		}
+
+		return value, false, fmt.Errorf("Get `%v`: %w", nst.name, err)
+	}
+	if nst.txn.isExpired(item) {
+		return value, false, nil
+	}
+
+	var valuePtr *ValueT
+	err = item.Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = decodeGob[ValueT](valueb)
+		return err
+	})
+	if err != nil {
+		return value, false, fmt.Errorf("Get `%v`: %w", nst.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Returns names of direct children of path, which have values or descendants
+// with values, in byte order. Empty path lists top-level segments. Subtrees
+// of children are skipped, not scanned.
+func (nst *NamespaceTree[ValueT]) Children(path string) ([]string, error) {
+	scanPrefix, err := nst.subtreePrefix(path)
+	if err != nil {
+		return nil, fmt.Errorf("Children `%v`: %w", nst.name, err)
+	}
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := nst.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var children []string
+	it.Seek(scanPrefix)
+	for it.ValidForPrefix(scanPrefix) {
+		err := nst.txn.checkContext()
+		if err != nil {
+			return nil, fmt.Errorf("Children `%v`: %w", nst.name, err)
+		}
+
+		rest := it.Item().Key()[len(scanPrefix):]
+		if len(rest) == 0 {
+			it.Next()
+			continue
+		}
+		child := rest
+		if i := bytes.IndexByte(rest, 0x00); i >= 0 {
+			child = rest[:i]
+		}
+		children = append(children, string(child))
+
+		// Subtree of child is stored right after it, and \x01 goes after \x00
+		// separator of its segments
+		it.Seek(joinKey(joinKey(scanPrefix, child), []byte{0x01}))
+	}
+
+	return children, nil
+}
+
+// Deletes value under path and values of all its descendants. Empty path
+// deletes the whole tree.
+func (nst *NamespaceTree[ValueT]) DeleteSubtree(path string) error {
+	key, err := nst.pathKey(path)
+	if err != nil {
+		return fmt.Errorf("DeleteSubtree `%v`: %w", nst.name, err)
+	}
+	scanPrefix, err := nst.subtreePrefix(path)
+	if err != nil {
+		return fmt.Errorf("DeleteSubtree `%v`: %w", nst.name, err)
+	}
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := nst.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var keys [][]byte
+	if len(key) != len(nst.prefix) {
+		keys = append(keys, key)
+	}
+	for it.Seek(scanPrefix); it.ValidForPrefix(scanPrefix); it.Next() {
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+
+	for _, key := range keys {
+		err = nst.txn.badgertxn.Delete(key)
+		if err != nil {
+			return fmt.Errorf("DeleteSubtree `%v`: %w", nst.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns stored key of path: namespace prefix and path segments separated by
+// \x00. Stored key of empty path is namespace prefix.
+func (nst *NamespaceTree[ValueT]) pathKey(path string) ([]byte, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nst.prefix, nil
+	}
+
+	segments := strings.Split(path, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("path `%v` contains empty segment", path)
+		}
+		if strings.ContainsRune(segment, '\x00') {
+			return nil, errors.New("path must not contain \\x00 symbol")
+		}
+	}
+
+	return joinKey(nst.prefix, []byte(strings.Join(segments, "\x00"))), nil
+}
+
+// Returns prefix of stored keys of descendants of path
+func (nst *NamespaceTree[ValueT]) subtreePrefix(path string) ([]byte, error) {
+	key, err := nst.pathKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == len(nst.prefix) {
+		return key, nil
+	}
+
+	return addPrefixToKey(key, nil), nil
+}
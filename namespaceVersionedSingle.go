@@ -0,0 +1,214 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stores single value keeping its previous versions, so it may be rolled back
+type NamespaceVersionedSingle[ValueT any] struct {
+	txn    Txn
+	name   string
+	prefix []byte
+	// Number of kept versions, 0 means all
+	keep int
+}
+
+// Value with its version returned by NamespaceVersionedSingle.History
+type ValueVersion[ValueT any] struct {
+	Version uint64
+	Value   ValueT
+}
+
+// Creates api for storing single value with history of versions. Every Set
+// stores value under a new version, starting from 1. Only keep latest versions
+// are kept, or all of them if keep is 0. Do not use pointer as a type for
+// ValueT. Name must not be empty.
+func NewNamespaceVersionedSingle[ValueT any](txn Txn, name string, keep int) *NamespaceVersionedSingle[ValueT] {
+	txn.validateNamespaceName(name)
+	if keep < 0 {
+		panic("keep must not be negative")
+	}
+	return &NamespaceVersionedSingle[ValueT]{
+		txn:    txn,
+		name:   name,
+		prefix: txn.namespacePrefix(name),
+		keep:   keep,
+	}
+}
+
+// Stores value as a new latest version and returns its number. Versions older
+// than kept number are deleted.
+func (nsv *NamespaceVersionedSingle[ValueT]) Set(value ValueT) (version uint64, err error) {
+	latest, err := nsv.latestVersion()
+	if err != nil {
+		return 0, fmt.Errorf("Set `%v`: %w", nsv.name, err)
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return 0, fmt.Errorf("Set `%v`: %w", nsv.name, err)
+	}
+
+	version = latest + 1
+	err = nsv.txn.badgertxn.Set(nsv.versionKey(version), valueb)
+	if err != nil {
+		return 0, fmt.Errorf("Set `%v`: %w", nsv.name, err)
+	}
+
+	if nsv.keep > 0 && version > uint64(nsv.keep) {
+		err = nsv.deleteUpTo(version - uint64(nsv.keep))
+		if err != nil {
+			return 0, fmt.Errorf("Set `%v`: %w", nsv.name, err)
+		}
+	}
+
+	return version, nil
+}
+
+// Returns latest value and its version. If no value is stored, returns zero
+// value and version 0.
+func (nsv *NamespaceVersionedSingle[ValueT]) Get() (value ValueT, version uint64, err error) {
+	history, err := nsv.History(1)
+	if err != nil {
+		return value, 0, fmt.Errorf("Get `%v`: %w", nsv.name, err)
+	}
+	if len(history) == 0 {
+		return value, 0, nil
+	}
+
+	return history[0].Value, history[0].Version, nil
+}
+
+// Returns value stored under passed version. Returns ok == false if version
+// does not exist or is already deleted.
+func (nsv *NamespaceVersionedSingle[ValueT]) GetVersion(version uint64) (value ValueT, ok bool, err error) {
+	item, err := nsv.txn.badgertxn.Get(nsv.versionKey(version))
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return value, false, nil
+		}
+
+		return value, false, fmt.Errorf("GetVersion `%v`: %w", nsv.name, err)
+	}
+
+	var valuePtr *ValueT
+	err = item.Value(func(valueb []byte) error {
+		var err error
+		valuePtr, err = decodeGob[ValueT](valueb)
+		return err
+	})
+	if err != nil {
+		return value, false, fmt.Errorf("GetVersion `%v`: %w", nsv.name, err)
+	}
+
+	return *valuePtr, true, nil
+}
+
+// Returns up to limit latest versions, newest first. If limit is 0, returns
+// all kept versions.
+func (nsv *NamespaceVersionedSingle[ValueT]) History(limit int) ([]ValueVersion[ValueT], error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Reverse = true
+	it := nsv.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var history []ValueVersion[ValueT]
+	for it.Seek(nsv.versionKey(^uint64(0))); it.ValidForPrefix(nsv.prefix); it.Next() {
+		if limit > 0 && len(history) == limit {
+			break
+		}
+
+		err := nsv.txn.checkContext()
+		if err != nil {
+			return nil, fmt.Errorf("History `%v`: %w", nsv.name, err)
+		}
+
+		item := it.Item()
+		var valuePtr *ValueT
+		err = item.Value(func(valueb []byte) error {
+			var err error
+			valuePtr, err = decodeGob[ValueT](valueb)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("History `%v`: %w", nsv.name, err)
+		}
+
+		history = append(history, ValueVersion[ValueT]{
+			Version: binary.BigEndian.Uint64(item.Key()[len(nsv.prefix):]),
+			Value:   *valuePtr,
+		})
+	}
+
+	return history, nil
+}
+
+// Stores value of passed version as a new latest version and returns its
+// number. Returns ErrVersionNotFound if version does not exist or is already
+// deleted.
+func (nsv *NamespaceVersionedSingle[ValueT]) Rollback(version uint64) (newVersion uint64, err error) {
+	value, ok, err := nsv.GetVersion(version)
+	if err != nil {
+		return 0, fmt.Errorf("Rollback `%v`: %w", nsv.name, err)
+	}
+	if !ok {
+		return 0, fmt.Errorf("Rollback `%v`: %w: %v", nsv.name, ErrVersionNotFound, version)
+	}
+
+	newVersion, err = nsv.Set(value)
+	if err != nil {
+		return 0, fmt.Errorf("Rollback `%v`: %w", nsv.name, err)
+	}
+
+	return newVersion, nil
+}
+
+func (nsv *NamespaceVersionedSingle[ValueT]) versionKey(version uint64) []byte {
+	return joinKey(nsv.prefix, uint64Bytes(version))
+}
+
+// Returns latest stored version, or 0 if there are no versions
+func (nsv *NamespaceVersionedSingle[ValueT]) latestVersion() (uint64, error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Reverse = true
+	iteratorOptions.PrefetchValues = false
+	it := nsv.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	it.Seek(nsv.versionKey(^uint64(0)))
+	if !it.ValidForPrefix(nsv.prefix) {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint64(it.Item().Key()[len(nsv.prefix):]), nil
+}
+
+// Deletes versions less than or equal to passed one
+func (nsv *NamespaceVersionedSingle[ValueT]) deleteUpTo(version uint64) error {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := nsv.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var keys [][]byte
+	for it.Seek(nsv.prefix); it.ValidForPrefix(nsv.prefix); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		if binary.BigEndian.Uint64(key[len(nsv.prefix):]) > version {
+			break
+		}
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		err := nsv.txn.badgertxn.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package instorage
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Converts s to lower case in Unicode NFC form. Meant to be passed to
+// NamespaceMultiple.WithKeyNormalizer for case-insensitive string keys, such
+// as emails.
+func NormalizeString(s string) string {
+	return norm.NFC.String(strings.ToLower(s))
+}
@@ -0,0 +1,69 @@
+package instorage
+
+import "time"
+
+// Callbacks receiving information about database operations, passed to
+// WithObserver. Any of callbacks may be nil.
+type Observer struct {
+	// Called after value is encoded with its size and encoding duration
+	OnEncode func(namespace string, bytes int, dur time.Duration)
+	// Called after value is decoded with its size and decoding duration
+	OnDecode func(namespace string, bytes int, dur time.Duration)
+}
+
+// Sets observer, which receives information about database operations
+func WithObserver(observer Observer) Option {
+	return func(dbopts *dbOptions) {
+		dbopts.observer = observer
+	}
+}
+
+// Calls log for every operation of namespaces, which takes threshold or
+// longer, with name of operation, like Get or Iter, and namespace name.
+// Encoding and decoding of values are reported separately as encode and
+// decode operations. Duration of Iter includes time spent in viewer.
+func WithSlowOpThreshold(threshold time.Duration, log func(op, namespace string, dur time.Duration)) Option {
+	return func(dbopts *dbOptions) {
+		dbopts.slowOpThreshold = threshold
+		dbopts.logSlowOp = log
+	}
+}
+
+func (dbopts *dbOptions) observeEncode(namespace string, bytes int, start time.Time) {
+	if dbopts == nil {
+		return
+	}
+
+	dur := time.Since(start)
+	if dbopts.observer.OnEncode != nil {
+		dbopts.observer.OnEncode(namespace, bytes, dur)
+	}
+	dbopts.checkSlowOp("encode", namespace, dur)
+}
+
+func (dbopts *dbOptions) observeDecode(namespace string, bytes int, start time.Time) {
+	if dbopts == nil {
+		return
+	}
+
+	dur := time.Since(start)
+	if dbopts.observer.OnDecode != nil {
+		dbopts.observer.OnDecode(namespace, bytes, dur)
+	}
+	dbopts.checkSlowOp("decode", namespace, dur)
+}
+
+// Reports operation started at start, if it is slow. Meant to be deferred.
+func (dbopts *dbOptions) observeOp(op, namespace string, start time.Time) {
+	if dbopts == nil || dbopts.logSlowOp == nil {
+		return
+	}
+
+	dbopts.checkSlowOp(op, namespace, time.Since(start))
+}
+
+func (dbopts *dbOptions) checkSlowOp(op, namespace string, dur time.Duration) {
+	if dbopts.logSlowOp != nil && dur >= dbopts.slowOpThreshold {
+		dbopts.logSlowOp(op, namespace, dur)
+	}
+}
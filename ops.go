@@ -0,0 +1,106 @@
+package instorage
+
+import "fmt"
+
+// Batch of write operations over multiple namespaces, which can be built
+// outside of transaction and applied atomically with Txn.Apply. Values are
+// encoded with gob, so namespace options changing value encoding are not
+// applied.
+type Ops struct {
+	ops []op
+}
+
+type op struct {
+	namespace string
+	// Encoded key of NamespaceMultiple, nil for NamespaceSingle
+	keyb   []byte
+	value  []byte
+	delete bool
+}
+
+// Returns number of recorded operations
+func (ops *Ops) Len() int {
+	return len(ops.ops)
+}
+
+// Records setting value for a key in NamespaceMultiple with passed name
+func RecordSet[KeyT comparable, ValueT any](ops *Ops, namespace string, key KeyT, value ValueT) error {
+	keyb, err := encodeGob(key)
+	if err != nil {
+		return fmt.Errorf("RecordSet `%v`: %w", namespace, err)
+	}
+
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("RecordSet `%v`: %w", namespace, err)
+	}
+
+	ops.ops = append(ops.ops, op{
+		namespace: namespace,
+		keyb:      keyb,
+		value:     valueb,
+	})
+
+	return nil
+}
+
+// Records deleting a key from NamespaceMultiple with passed name
+func RecordDelete[KeyT comparable](ops *Ops, namespace string, key KeyT) error {
+	keyb, err := encodeGob(key)
+	if err != nil {
+		return fmt.Errorf("RecordDelete `%v`: %w", namespace, err)
+	}
+
+	ops.ops = append(ops.ops, op{
+		namespace: namespace,
+		keyb:      keyb,
+		delete:    true,
+	})
+
+	return nil
+}
+
+// Records setting value of NamespaceSingle with passed name
+func RecordSetSingle[ValueT any](ops *Ops, namespace string, value ValueT) error {
+	valueb, err := encodeGob(value)
+	if err != nil {
+		return fmt.Errorf("RecordSetSingle `%v`: %w", namespace, err)
+	}
+
+	ops.ops = append(ops.ops, op{
+		namespace: namespace,
+		value:     valueb,
+	})
+
+	return nil
+}
+
+// Records deleting value of NamespaceSingle with passed name
+func RecordDeleteSingle(ops *Ops, namespace string) {
+	ops.ops = append(ops.ops, op{
+		namespace: namespace,
+		delete:    true,
+	})
+}
+
+// Applies all operations from ops in recorded order within this transaction
+func (txn Txn) Apply(ops *Ops) error {
+	for _, op := range ops.ops {
+		key := txn.singleKey(op.namespace)
+		if op.keyb != nil {
+			key = joinKey(txn.namespacePrefix(op.namespace), op.keyb)
+		}
+
+		var err error
+		if op.delete {
+			err = txn.badgertxn.Delete(key)
+		} else {
+			err = txn.badgertxn.Set(key, op.value)
+		}
+		if err != nil {
+			return fmt.Errorf("Apply: %w", err)
+		}
+	}
+
+	return nil
+}
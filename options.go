@@ -0,0 +1,148 @@
+package instorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/dgraph-io/badger/v3/options"
+)
+
+// Option configures database opened with Open
+type Option func(dbopts *dbOptions)
+
+type dbOptions struct {
+	badgerOptions badger.Options
+	clock         func() time.Time
+	observer      Observer
+	internedNames []string
+	// Set by WithRewriteKeys
+	rewriteKeys bool
+	// Called by GC repeater when number of levels waiting for compaction
+	// reaches compactionBacklogThreshold
+	onCompactionBacklog        func(stats Stats)
+	compactionBacklogThreshold int
+	// Set by WithMaxSize
+	maxSize        int64
+	evictionPolicy EvictionPolicy
+	// Set by WithExpiryCallback
+	expiryInterval time.Duration
+	onExpire       func(namespace string, keyb []byte)
+	// Set by WithSlowOpThreshold
+	slowOpThreshold time.Duration
+	logSlowOp       func(op, namespace string, dur time.Duration)
+	// Set by WithMaxConcurrentTxns
+	txnSlots     chan struct{}
+	failFastTxns bool
+	// Set by WithDefaultTimeout
+	defaultTimeout time.Duration
+}
+
+func newDBOptions(dbpath string, opts []Option) *dbOptions {
+	dbopts := &dbOptions{
+		badgerOptions: badger.DefaultOptions(dbpath).WithLoggingLevel(badger.ERROR),
+		clock:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(dbopts)
+	}
+
+	return dbopts
+}
+
+// Enables verification of value and table block checksums on every read, so
+// corrupted data is reported as an error instead of being silently returned.
+// Slows down reads.
+func WithVerifyChecksums(verify bool) Option {
+	return func(dbopts *dbOptions) {
+		mode := options.NoVerification
+		if verify {
+			mode = options.OnTableAndBlockRead
+		}
+
+		dbopts.badgerOptions = dbopts.badgerOptions.
+			WithVerifyValueChecksum(verify).
+			WithChecksumVerificationMode(mode)
+	}
+}
+
+// Sets clock used for computing and checking expiration of values, time.Now
+// by default. Values, considered expired by clock, are treated as absent. It is
+// useful for testing expiration without waiting, but note that badger itself
+// always discards values expired by real time.
+func WithClock(clock func() time.Time) Option {
+	return func(dbopts *dbOptions) {
+		dbopts.clock = clock
+	}
+}
+
+// Sets number of level zero tables, after which compaction to next level
+// starts, and number of them, after which writes are stalled until compaction
+// catches up. Badger defaults are 5 and 15. Raising them helps write-heavy
+// workloads to avoid stalls at the cost of slower reads. Stall must be greater
+// than num.
+func WithLevelZeroTables(num, stall int) Option {
+	if num < 1 || stall <= num {
+		panic("num must be positive and stall must be greater than num")
+	}
+
+	return func(dbopts *dbOptions) {
+		dbopts.badgerOptions = dbopts.badgerOptions.
+			WithNumLevelZeroTables(num).
+			WithNumLevelZeroTablesStall(stall)
+	}
+}
+
+// Sets false positive probability of bloom filters, which badger checks
+// before reading tables for a key. Badger default is 0.01. Lower values make
+// lookups of absent keys cheaper at the cost of memory. Must be between 0 and
+// 1. Observed share of misses is reported by DB.Stats.
+func WithBloomFalsePositive(falsePositive float64) Option {
+	if falsePositive <= 0 || falsePositive >= 1 {
+		panic("falsePositive must be between 0 and 1")
+	}
+
+	return func(dbopts *dbOptions) {
+		dbopts.badgerOptions = dbopts.badgerOptions.WithBloomFalsePositive(falsePositive)
+	}
+}
+
+// Sets timeout of transactions started by View, Update and other methods of
+// DB, which have no timeout of their own, like ViewWithTimeout has. Such
+// transactions fail with ErrTxnTimeout, which matches context.DeadlineExceeded,
+// when they run longer. Like with ViewWithTimeout, only iterations over
+// namespaces are stopped on timeout, other code is not interrupted.
+func WithDefaultTimeout(timeout time.Duration) Option {
+	if timeout <= 0 {
+		panic("timeout must be positive")
+	}
+
+	return func(dbopts *dbOptions) {
+		dbopts.defaultTimeout = timeout
+	}
+}
+
+// Returns ctx with default timeout set by WithDefaultTimeout, if ctx has no
+// deadline
+func (dbopts *dbOptions) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if dbopts.defaultTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, dbopts.defaultTimeout)
+}
+
+func withReadOnly() Option {
+	return func(dbopts *dbOptions) {
+		dbopts.badgerOptions = dbopts.badgerOptions.WithReadOnly(true)
+	}
+}
+
+func withInMemory() Option {
+	return func(dbopts *dbOptions) {
+		dbopts.badgerOptions = dbopts.badgerOptions.WithDir("").WithValueDir("").WithInMemory(true)
+	}
+}
@@ -0,0 +1,77 @@
+package instorage
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// IndexedNamespace indexed by string of values, which allows searching keys by
+// prefix of the string, for example for autocomplete
+type PrefixIndexedNamespace[KeyT comparable, ValueT any] struct {
+	*IndexedNamespace[KeyT, ValueT]
+}
+
+// Creates api for storing multiple key-value pairs searchable by prefix of
+// string returned by prefixKeyOf. Strings are stored as index bytes of
+// IndexedNamespace and compared byte by byte, so normalize them beforehand for
+// case-insensitive search. Do not use pointers as types for KeyT and ValueT.
+// Name must not be empty.
+func NewPrefixIndexedNamespace[KeyT comparable, ValueT any](txn Txn, name string, prefixKeyOf func(value ValueT) string) *PrefixIndexedNamespace[KeyT, ValueT] {
+	if prefixKeyOf == nil {
+		panic("prefixKeyOf must not be nil")
+	}
+	return &PrefixIndexedNamespace[KeyT, ValueT]{
+		IndexedNamespace: NewIndexedNamespace[KeyT](txn, name, func(value ValueT) []byte {
+			return []byte(prefixKeyOf(value))
+		}),
+	}
+}
+
+// Returns up to limit keys, which values have strings starting with prefix, in
+// order of strings. Only index entries matching prefix are scanned. If limit is
+// not positive, all matching keys are returned.
+func (pin *PrefixIndexedNamespace[KeyT, ValueT]) SearchPrefix(prefix string, limit int) ([]KeyT, error) {
+	seekKey := pin.indexKey([]byte(prefix), nil)
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	iteratorOptions.Prefix = seekKey
+
+	it := pin.data.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var keys []KeyT
+	for it.Seek(seekKey); it.ValidForPrefix(seekKey); it.Next() {
+		if limit > 0 && len(keys) == limit {
+			break
+		}
+
+		err := pin.data.txn.checkContext()
+		if err != nil {
+			return nil, fmt.Errorf("SearchPrefix `%v`: %w", pin.data.name, err)
+		}
+
+		item := it.Item()
+
+		keyb, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, fmt.Errorf("SearchPrefix `%v`: %w", pin.data.name, err)
+		}
+
+		// Prefix may match bytes of encoded key following shorter string
+		indexb := item.Key()[len(pin.indexPrefix) : len(item.Key())-len(keyb)]
+		if !bytes.HasPrefix(indexb, []byte(prefix)) {
+			continue
+		}
+
+		keyPtr, err := decodeGob[KeyT](keyb)
+		if err != nil {
+			return nil, fmt.Errorf("SearchPrefix `%v`: %w", pin.data.name, err)
+		}
+		keys = append(keys, *keyPtr)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,48 @@
+package instorage
+
+import (
+	"fmt"
+)
+
+// Key of NamespaceMultiple encoded once by Prepare, so repeated operations on
+// the same key do not encode it again. It is bound to namespace and its
+// transaction.
+type PreparedKey[KeyT comparable, ValueT any] struct {
+	nsm  *NamespaceMultiple[KeyT, ValueT]
+	key  KeyT
+	keyb []byte
+}
+
+// Encodes key for repeated Set, Get and Delete
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Prepare(key KeyT) (*PreparedKey[KeyT, ValueT], error) {
+	keyb, err := nsm.encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("Prepare `%v`: %w", nsm.name, err)
+	}
+
+	return &PreparedKey[KeyT, ValueT]{
+		nsm:  nsm,
+		key:  key,
+		keyb: keyb,
+	}, nil
+}
+
+// Returns key passed to Prepare
+func (pk *PreparedKey[KeyT, ValueT]) Key() KeyT {
+	return pk.key
+}
+
+// Same as NamespaceMultiple.Set with prepared key
+func (pk *PreparedKey[KeyT, ValueT]) Set(value ValueT) error {
+	return pk.nsm.set(pk.key, pk.keyb, value)
+}
+
+// Same as NamespaceMultiple.Get with prepared key
+func (pk *PreparedKey[KeyT, ValueT]) Get() (value ValueT, ok bool, err error) {
+	return pk.nsm.get(pk.keyb)
+}
+
+// Same as NamespaceMultiple.Delete with prepared key
+func (pk *PreparedKey[KeyT, ValueT]) Delete() error {
+	return pk.nsm.delete(pk.key, pk.keyb)
+}
@@ -0,0 +1,110 @@
+package instorage
+
+import "testing"
+
+type preparedKeyID struct {
+	Tenant string
+	ID     int
+}
+
+func TestPreparedKey(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[preparedKeyID, int](txn, "counters")
+		pk, err := nsm.Prepare(preparedKeyID{Tenant: "a", ID: 1})
+		if err != nil {
+			return err
+		}
+
+		err = pk.Set(5)
+		if err != nil {
+			return err
+		}
+
+		// Prepared key points to the same pair as unprepared one
+		value, ok, err := nsm.Get(pk.Key())
+		if err != nil {
+			return err
+		}
+		if !ok || value != 5 {
+			t.Errorf("Get returned %v, %v", value, ok)
+		}
+
+		err = pk.Delete()
+		if err != nil {
+			return err
+		}
+		_, ok, err = pk.Get()
+		if ok {
+			t.Error("Get found deleted pair")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Compares Set and Get with prepared key against the normal path. Operations
+// are made in batches of 1000 per transaction, and key is prepared once for
+// each of them.
+func BenchmarkPreparedKey(b *testing.B) {
+	key := preparedKeyID{Tenant: "tenant", ID: 42}
+
+	b.Run("normal", func(b *testing.B) {
+		benchmarkBatches(b, func(txn Txn, n int) error {
+			nsm := NewNamespaceMultiple[preparedKeyID, int](txn, "counters")
+			for i := 0; i < n; i++ {
+				err := nsm.Set(key, i)
+				if err != nil {
+					return err
+				}
+				_, _, err = nsm.Get(key)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	b.Run("prepared", func(b *testing.B) {
+		benchmarkBatches(b, func(txn Txn, n int) error {
+			pk, err := NewNamespaceMultiple[preparedKeyID, int](txn, "counters").Prepare(key)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				err := pk.Set(i)
+				if err != nil {
+					return err
+				}
+				_, _, err = pk.Get()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Runs b.N operations of batch in transactions of up to 1000 operations
+func benchmarkBatches(b *testing.B, batch func(txn Txn, n int) error) {
+	db := openTestDB(b)
+
+	b.ResetTimer()
+	for done := 0; done < b.N; done += 1000 {
+		n := b.N - done
+		if n > 1000 {
+			n = 1000
+		}
+
+		err := db.Update(func(txn Txn) error {
+			return batch(txn, n)
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Encodes values of basic types without gob framing. Returns ok == false for
+// other types, including types defined on top of basic ones.
+func encodePrimitive(data any) (b []byte, ok bool) {
+	switch v := data.(type) {
+	case int64:
+		return uint64Bytes(uint64(v)), true
+	case int:
+		return uint64Bytes(uint64(v)), true
+	case int32:
+		return uint32Bytes(uint32(v)), true
+	case uint64:
+		return uint64Bytes(v), true
+	case uint:
+		return uint64Bytes(uint64(v)), true
+	case uint32:
+		return uint32Bytes(v), true
+	case float64:
+		return uint64Bytes(math.Float64bits(v)), true
+	case bool:
+		if v {
+			return []byte{1}, true
+		}
+		return []byte{0}, true
+	case string:
+		return []byte(v), true
+	case []byte:
+		return append([]byte{}, v...), true
+	}
+
+	return nil, false
+}
+
+// Decodes values encoded by encodePrimitive. Returns ok == false, if DataT is
+// not supported by encodePrimitive.
+func decodePrimitive[DataT any](b []byte) (dataPtr *DataT, ok bool, err error) {
+	dataPtr = new(DataT)
+
+	size := 0
+	switch any(dataPtr).(type) {
+	case *int64, *int, *uint64, *uint, *float64:
+		size = 8
+	case *int32, *uint32:
+		size = 4
+	case *bool:
+		size = 1
+	}
+	if size != 0 && len(b) != size {
+		return dataPtr, true, fmt.Errorf("decodePrimitive: %v bytes value can not be decoded as %T", len(b), *dataPtr)
+	}
+
+	switch p := any(dataPtr).(type) {
+	case *int64:
+		*p = int64(binary.BigEndian.Uint64(b))
+	case *int:
+		*p = int(binary.BigEndian.Uint64(b))
+	case *int32:
+		*p = int32(binary.BigEndian.Uint32(b))
+	case *uint64:
+		*p = binary.BigEndian.Uint64(b)
+	case *uint:
+		*p = uint(binary.BigEndian.Uint64(b))
+	case *uint32:
+		*p = binary.BigEndian.Uint32(b)
+	case *float64:
+		*p = math.Float64frombits(binary.BigEndian.Uint64(b))
+	case *bool:
+		*p = b[0] != 0
+	case *string:
+		*p = string(b)
+	case *[]byte:
+		*p = append([]byte{}, b...)
+	default:
+		return dataPtr, false, nil
+	}
+
+	return dataPtr, true, nil
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
@@ -0,0 +1,181 @@
+package instorage
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPrimitiveRoundTrip(t *testing.T) {
+	for _, value := range []any{int64(-42), int(7), int32(-3), uint64(1 << 60), uint(5), uint32(9), 3.5, true, "text", []byte{1, 2}} {
+		b, ok := encodePrimitive(value)
+		if !ok {
+			t.Fatalf("encodePrimitive(%T) not supported", value)
+		}
+
+		var decoded any
+		var err error
+		switch value.(type) {
+		case int64:
+			decoded, err = decodePrimitiveValue[int64](b)
+		case int:
+			decoded, err = decodePrimitiveValue[int](b)
+		case int32:
+			decoded, err = decodePrimitiveValue[int32](b)
+		case uint64:
+			decoded, err = decodePrimitiveValue[uint64](b)
+		case uint:
+			decoded, err = decodePrimitiveValue[uint](b)
+		case uint32:
+			decoded, err = decodePrimitiveValue[uint32](b)
+		case float64:
+			decoded, err = decodePrimitiveValue[float64](b)
+		case bool:
+			decoded, err = decodePrimitiveValue[bool](b)
+		case string:
+			decoded, err = decodePrimitiveValue[string](b)
+		case []byte:
+			decoded, err = decodePrimitiveValue[[]byte](b)
+			decoded = string(decoded.([]byte))
+			value = string(value.([]byte))
+		}
+		if err != nil {
+			t.Fatalf("decoding %T: %v", value, err)
+		}
+		if decoded != value {
+			t.Errorf("decoded %v, want %v", decoded, value)
+		}
+	}
+}
+
+func decodePrimitiveValue[DataT any](b []byte) (DataT, error) {
+	var value DataT
+	dataPtr, ok, err := decodePrimitive[DataT](b)
+	if err != nil || !ok {
+		return value, err
+	}
+
+	return *dataPtr, nil
+}
+
+func TestPrimitiveSmallerThanGob(t *testing.T) {
+	// Gob stores numbers as varints, so only big ones are bigger than fixed
+	// size primitive encoding
+	for _, value := range []any{int64(1) << 60, true, "text", []byte{1, 2}} {
+		primitive, _ := encodePrimitive(value)
+		gob, err := encodeGob(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(primitive) >= len(gob) {
+			t.Errorf("primitive %T takes %v bytes, gob one %v", value, len(primitive), len(gob))
+		}
+	}
+}
+
+// Values used by codec benchmarks
+var primitiveBenchValues = []struct {
+	name  string
+	value any
+}{
+	{"small_int64", int64(1000)},
+	{"big_int64", int64(1) << 60},
+	{"float64", 3.5},
+	{"bool", true},
+	{"string", "some text"},
+}
+
+// Reports speed and size of values encoded with primitive codec and gob
+func BenchmarkEncodePrimitive(b *testing.B) {
+	for _, bench := range primitiveBenchValues {
+		b.Run(bench.name+"/primitive", func(b *testing.B) {
+			var valueb []byte
+			for i := 0; i < b.N; i++ {
+				valueb, _ = encodePrimitive(bench.value)
+			}
+			b.ReportMetric(float64(len(valueb)), "bytes/value")
+		})
+		b.Run(bench.name+"/gob", func(b *testing.B) {
+			var valueb []byte
+			for i := 0; i < b.N; i++ {
+				var err error
+				valueb, err = encodeGob(bench.value)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(valueb)), "bytes/value")
+		})
+	}
+}
+
+func BenchmarkDecodeInt64(b *testing.B) {
+	b.Run("primitive", func(b *testing.B) {
+		valueb, _ := encodePrimitive(int64(1234567))
+		for i := 0; i < b.N; i++ {
+			_, _, err := decodePrimitive[int64](valueb)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("gob", func(b *testing.B) {
+		valueb, err := encodeGob(int64(1234567))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < b.N; i++ {
+			_, err := decodeGob[int64](valueb)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Reports stored bytes per entry of NamespaceMultiple with and without
+// WithPrimitiveEncoding
+func BenchmarkPrimitiveEncodingStorage(b *testing.B) {
+	b.Run("int64", func(b *testing.B) {
+		benchmarkPrimitiveEncodingStorage(b, func(i int) int64 { return int64(i) })
+	})
+	b.Run("string", func(b *testing.B) {
+		benchmarkPrimitiveEncodingStorage(b, func(i int) string { return strconv.Itoa(i) })
+	})
+}
+
+func benchmarkPrimitiveEncodingStorage[ValueT any](b *testing.B, valueOf func(i int) ValueT) {
+	for _, primitive := range []bool{false, true} {
+		name := "gob"
+		if primitive {
+			name = "primitive"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			db := openTestDB(b)
+
+			b.ResetTimer()
+			for start := 0; start < b.N; start += 1000 {
+				err := db.Update(func(txn Txn) error {
+					nsm := NewNamespaceMultiple[int, ValueT](txn, name)
+					if primitive {
+						nsm.WithPrimitiveEncoding()
+					}
+					for i := start; i < start+1000 && i < b.N; i++ {
+						err := nsm.Set(i, valueOf(i))
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(storedValueBytes(b, db, name))/float64(b.N), "bytes/entry")
+		})
+	}
+}
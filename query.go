@@ -0,0 +1,372 @@
+package instorage
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Key-value pair returned from NamespaceMultiple
+type KeyValue[KeyT comparable, ValueT any] struct {
+	Key   KeyT
+	Value ValueT
+}
+
+// Query for NamespaceMultiple.Scan. All fields are optional. Keys are ordered
+// by their gob encoded bytes, which may differ from natural order of KeyT.
+type Query[KeyT comparable, ValueT any] struct {
+	// Key to start scan from, inclusive
+	StartKey *KeyT
+	// Maximal number of returned pairs, 0 means no limit
+	Limit int
+	// Scan in reverse key order
+	Reverse bool
+	// Pairs with keys for which KeyFilter returns false are skipped. Called
+	// before value is decoded.
+	KeyFilter func(key KeyT) bool
+	// Pairs with values for which ValueFilter returns false are skipped
+	ValueFilter func(value ValueT) bool
+}
+
+// Maximal size of badger keys
+const maxKeySize = 65000
+
+// Returns the smallest key greater than all keys starting with prefix, which
+// reverse iterators seek to start from the last of them: prefix with its last
+// byte incremented, with carry. Prefix of only 0xFF bytes has no such key, so
+// it is padded with 0xFF bytes to maximal key size instead.
+func prefixUpperBound(prefix []byte) []byte {
+	bound := append([]byte{}, prefix...)
+	for i := len(bound) - 1; i >= 0; i-- {
+		if bound[i] != 0xFF {
+			bound[i]++
+			return bound[:i+1]
+		}
+	}
+
+	return append(bound, bytes.Repeat([]byte{0xFF}, maxKeySize-len(bound))...)
+}
+
+// Returns key-value pairs matching passed query
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Scan(q Query[KeyT, ValueT]) ([]KeyValue[KeyT, ValueT], error) {
+	prefix := nsm.keyPrefix()
+
+	seekKey := prefix
+	if q.StartKey != nil {
+		keyb, err := nsm.encodeKey(*q.StartKey)
+		if err != nil {
+			return nil, fmt.Errorf("Scan `%v`: %w", nsm.name, err)
+		}
+		seekKey = joinKey(prefix, keyb)
+	} else if q.Reverse {
+		seekKey = prefixUpperBound(prefix)
+	}
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Reverse = q.Reverse
+	iteratorOptions.Prefix = prefix
+
+	it := nsm.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	it.Seek(seekKey)
+	// Reverse iterator stops at upper bound itself, if it is stored
+	if q.Reverse && q.StartKey == nil && it.Item() != nil && !it.ValidForPrefix(prefix) {
+		it.Next()
+	}
+
+	var kvs []KeyValue[KeyT, ValueT]
+	for ; it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return nil, fmt.Errorf("Scan `%v`: %w", nsm.name, err)
+		}
+
+		if q.Limit > 0 && len(kvs) >= q.Limit {
+			break
+		}
+
+		item := it.Item()
+		if nsm.txn.isExpired(item) {
+			continue
+		}
+
+		keyPtr, err := decodeGob[KeyT](item.Key()[len(prefix):])
+		if err != nil {
+			return nil, fmt.Errorf("Scan `%v`: %w", nsm.name, err)
+		}
+		if q.KeyFilter != nil && !q.KeyFilter(*keyPtr) {
+			continue
+		}
+
+		var valuePtr *ValueT
+		err = item.Value(func(valueb []byte) error {
+			var err error
+			valuePtr, err = nsm.decodeValue(valueb)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Scan `%v`: %w", nsm.name, err)
+		}
+		if q.ValueFilter != nil && !q.ValueFilter(*valuePtr) {
+			continue
+		}
+
+		err = nsm.checkResultSize(len(kvs) + 1)
+		if err != nil {
+			return nil, fmt.Errorf("Scan `%v`: %w", nsm.name, err)
+		}
+		kvs = append(kvs, KeyValue[KeyT, ValueT]{
+			Key:   *keyPtr,
+			Value: *valuePtr,
+		})
+	}
+
+	return kvs, nil
+}
+
+// Makes Scan, AllSized and SortedByValue fail with ErrResultTooLarge instead
+// of returning more than maxResults pairs, which protects from loading
+// unexpectedly big namespace into memory. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithMaxResults(maxResults int) *NamespaceMultiple[KeyT, ValueT] {
+	nsm.maxResults = maxResults
+	return nsm
+}
+
+// Returns ErrResultTooLarge, if n pairs exceed limit set by WithMaxResults
+func (nsm *NamespaceMultiple[KeyT, ValueT]) checkResultSize(n int) error {
+	if nsm.maxResults > 0 && n > nsm.maxResults {
+		return fmt.Errorf("%w: more than %v pairs", ErrResultTooLarge, nsm.maxResults)
+	}
+
+	return nil
+}
+
+// Returns all key-value pairs in key order. Result is preallocated with
+// capacityHint elements, for example from ApproxCount, which avoids repeated
+// growth of slice for big namespaces.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) AllSized(capacityHint int) ([]KeyValue[KeyT, ValueT], error) {
+	if capacityHint < 0 {
+		capacityHint = 0
+	}
+	kvs := make([]KeyValue[KeyT, ValueT], 0, capacityHint)
+
+	err := nsm.iterItems(nsm.keyPrefix(), nil, func(key KeyT, value ValueT) (bool, error) {
+		err := nsm.checkResultSize(len(kvs) + 1)
+		if err != nil {
+			return false, err
+		}
+		kvs = append(kvs, KeyValue[KeyT, ValueT]{
+			Key:   key,
+			Value: value,
+		})
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AllSized `%v`: %w", nsm.name, err)
+	}
+
+	return kvs, nil
+}
+
+// Returns all key-value pairs sorted by value with less, pairs with equal
+// values are kept in key order. All pairs are loaded into memory, so it suits
+// only small namespaces. For sorted access to big namespaces use
+// IndexedNamespace.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) SortedByValue(less func(a, b ValueT) bool) ([]KeyValue[KeyT, ValueT], error) {
+	var kvs []KeyValue[KeyT, ValueT]
+
+	err := nsm.iterItems(nsm.keyPrefix(), nil, func(key KeyT, value ValueT) (bool, error) {
+		err := nsm.checkResultSize(len(kvs) + 1)
+		if err != nil {
+			return false, err
+		}
+		kvs = append(kvs, KeyValue[KeyT, ValueT]{
+			Key:   key,
+			Value: value,
+		})
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SortedByValue `%v`: %w", nsm.name, err)
+	}
+
+	sort.SliceStable(kvs, func(i, j int) bool {
+		return less(kvs[i].Value, kvs[j].Value)
+	})
+
+	return kvs, nil
+}
+
+// Key and size of its stored value returned by NamespaceMultiple.TopBySize
+type KeyValueSize[KeyT comparable] struct {
+	Key KeyT
+	// Size of encoded value in bytes
+	Size int64
+}
+
+// Returns up to n keys with biggest stored values, sorted by size in
+// descending order. Values are not read, so it is cheap even for namespaces
+// with big values.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) TopBySize(n int) ([]KeyValueSize[KeyT], error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.PrefetchValues = false
+	it := nsm.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	top := &sizeHeap[KeyT]{}
+
+	prefix := nsm.keyPrefix()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return nil, fmt.Errorf("TopBySize `%v`: %w", nsm.name, err)
+		}
+
+		item := it.Item()
+		if nsm.txn.isExpired(item) {
+			continue
+		}
+
+		size := item.ValueSize()
+		if top.Len() == n && size <= (*top)[0].Size {
+			continue
+		}
+
+		keyPtr, err := decodeGob[KeyT](item.Key()[len(prefix):])
+		if err != nil {
+			return nil, fmt.Errorf("TopBySize `%v`: %w", nsm.name, err)
+		}
+
+		heap.Push(top, KeyValueSize[KeyT]{
+			Key:  *keyPtr,
+			Size: size,
+		})
+		if top.Len() > n {
+			heap.Pop(top)
+		}
+	}
+
+	sizes := make([]KeyValueSize[KeyT], top.Len())
+	for i := len(sizes) - 1; i >= 0; i-- {
+		sizes[i] = heap.Pop(top).(KeyValueSize[KeyT])
+	}
+
+	return sizes, nil
+}
+
+// Min-heap of key sizes, used by TopBySize
+type sizeHeap[KeyT comparable] []KeyValueSize[KeyT]
+
+func (h sizeHeap[KeyT]) Len() int           { return len(h) }
+func (h sizeHeap[KeyT]) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h sizeHeap[KeyT]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *sizeHeap[KeyT]) Push(x any) {
+	*h = append(*h, x.(KeyValueSize[KeyT]))
+}
+
+func (h *sizeHeap[KeyT]) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Entry, which could not be decoded by NamespaceMultiple.ScanWithReport
+type ScanFailure struct {
+	// Stored key, including namespace prefix
+	RawKey []byte
+	Err    error
+}
+
+// Calls cb for every key-value pair after from, or for all pairs if from is
+// nil. Entries, which can not be decoded, are skipped and returned as
+// failures instead of stopping the scan. Returns the last scanned key, which
+// may be passed as from to resume scan, for example after ErrTxnTimeout.
+// lastKey is nil if no keys were scanned.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) ScanWithReport(from *KeyT, cb func(key KeyT, value ValueT)) (lastKey *KeyT, failures []ScanFailure, err error) {
+	prefix := nsm.keyPrefix()
+
+	seekKey := prefix
+	var fromKey []byte
+	if from != nil {
+		keyb, err := nsm.encodeKey(*from)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ScanWithReport `%v`: %w", nsm.name, err)
+		}
+		fromKey = joinKey(prefix, keyb)
+		seekKey = fromKey
+	}
+
+	it := nsm.txn.badgertxn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Seek(seekKey); it.ValidForPrefix(prefix); it.Next() {
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return lastKey, failures, fmt.Errorf("ScanWithReport `%v`: %w", nsm.name, err)
+		}
+
+		item := it.Item()
+		if fromKey != nil && bytes.Equal(item.Key(), fromKey) {
+			continue
+		}
+		if nsm.txn.isExpired(item) {
+			continue
+		}
+
+		keyPtr, err := decodeGob[KeyT](item.Key()[len(prefix):])
+		if err != nil {
+			failures = append(failures, ScanFailure{
+				RawKey: item.KeyCopy(nil),
+				Err:    err,
+			})
+			continue
+		}
+		lastKey = keyPtr
+
+		var valuePtr *ValueT
+		err = item.Value(func(valueb []byte) error {
+			var err error
+			valuePtr, err = nsm.decodeValue(valueb)
+			return err
+		})
+		if err != nil {
+			failures = append(failures, ScanFailure{
+				RawKey: item.KeyCopy(nil),
+				Err:    err,
+			})
+			continue
+		}
+
+		cb(*keyPtr, *valuePtr)
+	}
+
+	return lastKey, failures, nil
+}
+
+// Folds all key-value pairs of namespace into accumulator in one pass, in
+// order of encoded keys, starting with initial. Pairs are not collected in
+// memory. Go does not allow type parameters on methods, so it is a function.
+func Reduce[KeyT comparable, ValueT any, AccT any](nsm *NamespaceMultiple[KeyT, ValueT], initial AccT, fn func(acc AccT, key KeyT, value ValueT) (AccT, error)) (AccT, error) {
+	acc := initial
+
+	err := nsm.Iter(func(key KeyT, value ValueT) (bool, error) {
+		var err error
+		acc, err = fn(acc, key, value)
+		return err != nil, err
+	})
+	if err != nil {
+		return acc, fmt.Errorf("Reduce `%v`: %w", nsm.name, err)
+	}
+
+	return acc, nil
+}
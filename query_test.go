@@ -0,0 +1,79 @@
+package instorage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestScanReverseIncludesLongKeys(t *testing.T) {
+	db := openTestDB(t)
+
+	// Gob messages of 128-255 bytes start with 0xFF byte
+	longKey := strings.Repeat("k", 150)
+	keys := []string{"a", "b", longKey}
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, int](txn, "values")
+		for i, key := range keys {
+			err := nsm.Set(key, i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Key right after namespace keys is stored, so reverse iterator lands on it
+	err = db.Badger().Update(func(badgertxn *badger.Txn) error {
+		return badgertxn.Set(prefixUpperBound(db.state.namespacePrefix("values")), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		kvs, err := NewNamespaceMultiple[string, int](txn, "values").Scan(Query[string, int]{Reverse: true})
+		if err != nil {
+			return err
+		}
+		if len(kvs) != len(keys) {
+			t.Fatalf("Scan returned %v pairs, want %v", len(kvs), len(keys))
+		}
+		found := false
+		for _, kv := range kvs {
+			found = found || kv.Key == longKey
+		}
+		if !found {
+			t.Error("Scan skipped long key")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefixUpperBound(t *testing.T) {
+	for _, test := range []struct {
+		prefix, bound string
+	}{
+		{"ab\x00", "ab\x01"},
+		{"a\xff", "b"},
+		{"a\xff\xff", "b"},
+	} {
+		bound := string(prefixUpperBound([]byte(test.prefix)))
+		if bound != test.bound {
+			t.Errorf("prefixUpperBound(%q) = %q, want %q", test.prefix, bound, test.bound)
+		}
+	}
+
+	bound := prefixUpperBound([]byte{0xFF})
+	if len(bound) != maxKeySize || strings.Trim(string(bound), "\xff") != "" {
+		t.Errorf("prefixUpperBound of 0xFF prefix returned %v bytes", len(bound))
+	}
+}
@@ -0,0 +1,93 @@
+package instorage
+
+import (
+	"sync"
+	"time"
+)
+
+// Token bucket limiting writes to namespace, shared by all transactions
+type writeLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// Makes Set, SetWithExpiry, Delete and CompareAndDelete of namespace fail with
+// ErrRateLimited, or wait if block is true, when namespace is written more
+// than opsPerSecond times per second. Limit is shared by all transactions
+// using namespace with the same name, bursts up to opsPerSecond writes are
+// allowed. Waiting happens within transaction, so it holds transaction open.
+// Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithWriteRateLimit(opsPerSecond int, block bool) *NamespaceMultiple[KeyT, ValueT] {
+	if opsPerSecond <= 0 {
+		panic("opsPerSecond must be positive")
+	}
+	if nsm.txn.state == nil {
+		return nsm
+	}
+
+	limiter, _ := nsm.txn.state.writeLimiters.LoadOrStore(string(nsm.prefix), &writeLimiter{
+		rate:   float64(opsPerSecond),
+		tokens: float64(opsPerSecond),
+		last:   time.Now(),
+	})
+	nsm.writeLimiter = limiter.(*writeLimiter)
+	nsm.writeLimiter.setRate(float64(opsPerSecond))
+	nsm.blockOnRateLimit = block
+	return nsm
+}
+
+// Takes token for a single write of namespace, if its rate is limited
+func (nsm *NamespaceMultiple[KeyT, ValueT]) takeWriteToken() error {
+	if nsm.writeLimiter == nil {
+		return nil
+	}
+
+	for {
+		wait := nsm.writeLimiter.take()
+		if wait == 0 {
+			return nil
+		}
+		if !nsm.blockOnRateLimit {
+			return ErrRateLimited
+		}
+
+		time.Sleep(wait)
+
+		err := nsm.txn.checkContext()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (wl *writeLimiter) setRate(rate float64) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.rate = rate
+	if wl.tokens > rate {
+		wl.tokens = rate
+	}
+}
+
+// Takes token and returns 0, or returns time until the next token is available
+func (wl *writeLimiter) take() time.Duration {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	now := time.Now()
+	wl.tokens += now.Sub(wl.last).Seconds() * wl.rate
+	if wl.tokens > wl.rate {
+		wl.tokens = wl.rate
+	}
+	wl.last = now
+
+	if wl.tokens >= 1 {
+		wl.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - wl.tokens) / wl.rate * float64(time.Second))
+}
@@ -0,0 +1,71 @@
+package instorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Stored while database is opened, so it remains in database which was not
+// closed properly
+var openMarkerKey = reservedKey("open")
+
+// Reports whether database was not closed with Close or CloseCompact after it
+// was opened last time, for example because process crashed or was killed.
+// Badger recovers such database at Open by replaying its logs, so writes
+// committed without sync may be lost.
+func (db *DB[TxnAPIT]) WasRecovered() bool {
+	return db.recovered
+}
+
+// Stores open marker and reports whether it was stored already. Read-only
+// database is only checked.
+func markOpen(badgerdb *badger.DB) (recovered bool, err error) {
+	check := func(badgertxn *badger.Txn) error {
+		_, err := badgertxn.Get(openMarkerKey)
+		if err == nil {
+			recovered = true
+			return nil
+		}
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if badgerdb.Opts().ReadOnly {
+		err = badgerdb.View(check)
+	} else {
+		err = badgerdb.Update(func(badgertxn *badger.Txn) error {
+			err := check(badgertxn)
+			if err != nil {
+				return err
+			}
+
+			return badgertxn.Set(openMarkerKey, nil)
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("markOpen: %w", err)
+	}
+
+	return recovered, nil
+}
+
+// Deletes open marker before database is closed
+func markClosed(badgerdb *badger.DB) error {
+	if badgerdb.Opts().ReadOnly {
+		return nil
+	}
+
+	err := badgerdb.Update(func(badgertxn *badger.Txn) error {
+		return badgertxn.Delete(openMarkerKey)
+	})
+	if err != nil {
+		return fmt.Errorf("markClosed: %w", err)
+	}
+
+	return nil
+}
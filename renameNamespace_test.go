@@ -0,0 +1,152 @@
+package instorage
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Opens database with clock, which may be advanced, and records expired pairs
+// as namespace and key
+func openExpiryTestDB(t *testing.T) (db *DB[Txn], advance func(d time.Duration), expired func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	now := time.Now()
+	var reported []string
+
+	db = openTestDB(t,
+		WithClock(func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		}),
+		WithExpiryCallback(time.Hour, func(namespace string, keyb []byte) {
+			key, err := DecodeKey[string](keyb)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, namespace+"/"+key)
+		}),
+	)
+
+	advance = func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	expired = func() []string {
+		err := db.processExpired()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(reported)
+		return reported
+	}
+
+	return db, advance, expired
+}
+
+// Writes revisioned pair, expiring pair and idempotent pair to namespace
+func fillReservedKeys(t *testing.T, db *DB[Txn], name string) {
+	t.Helper()
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, string](txn, name).WithRevision()
+		err := nsm.Set("a", "a")
+		if err != nil {
+			return err
+		}
+		err = nsm.SetWithTTL("expiring", "b", time.Minute)
+		if err != nil {
+			return err
+		}
+		_, err = nsm.AppendIdempotent("request", "c", "c")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks that namespace has revision and idempotency marker written by
+// fillReservedKeys
+func checkReservedKeys(t *testing.T, db *DB[Txn], name string) {
+	t.Helper()
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, string](txn, name).WithRevision()
+		revision, err := nsm.Revision()
+		if err != nil {
+			return err
+		}
+		if revision != 3 {
+			t.Errorf("revision of `%v` is %v, expected 3", name, revision)
+		}
+		applied, err := nsm.AppendIdempotent("request", "c", "c")
+		if err != nil {
+			return err
+		}
+		if applied {
+			t.Errorf("idempotency key of `%v` is not used", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameNamespaceMovesReservedKeys(t *testing.T) {
+	db, advance, expired := openExpiryTestDB(t)
+	fillReservedKeys(t, db, "old")
+
+	err := db.RenameNamespace("old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReservedKeys(t, db, "new")
+
+	err = db.View(func(txn Txn) error {
+		revision, err := NewNamespaceMultiple[string, string](txn, "old").Revision()
+		if err != nil {
+			return err
+		}
+		if revision != 0 {
+			t.Errorf("revision of renamed namespace is %v, expected 0", revision)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	advance(2 * time.Minute)
+	got := expired()
+	if len(got) != 1 || got[0] != "new/expiring" {
+		t.Errorf("expired %v, expected [new/expiring]", got)
+	}
+}
+
+func TestSnapshotNamespaceCopiesReservedKeys(t *testing.T) {
+	db, advance, expired := openExpiryTestDB(t)
+	fillReservedKeys(t, db, "src")
+
+	err := db.SnapshotNamespace("src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReservedKeys(t, db, "src")
+	checkReservedKeys(t, db, "dst")
+
+	advance(2 * time.Minute)
+	got := expired()
+	if len(got) != 2 || got[0] != "dst/expiring" || got[1] != "src/expiring" {
+		t.Errorf("expired %v, expected [dst/expiring src/expiring]", got)
+	}
+}
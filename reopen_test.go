@@ -0,0 +1,100 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestReopenKeepsData(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceSingle[string](txn, "value").Set("kept")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Reopen(db.Badger().Opts().WithNumVersionsToKeep(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		value, err := NewNamespaceSingle[string](txn, "value").Get()
+		if value != "kept" {
+			t.Errorf("Get returned %q", value)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReopenFailureRestoresPreviousOptions(t *testing.T) {
+	db, err := Open(t.TempDir(), func(txn Txn) Txn { return txn })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Badger rejects value log files smaller than 1 MB
+	err = db.Reopen(db.Badger().Opts().WithValueLogFileSize(1))
+	if err == nil {
+		t.Fatal("Reopen with invalid options succeeded")
+	}
+
+	err = db.Update(func(txn Txn) error {
+		return NewNamespaceSingle[int](txn, "value").Set(1)
+	})
+	if err != nil {
+		t.Fatalf("Update after failed Reopen: %v", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedRestoreMakesDBUnusable(t *testing.T) {
+	db, err := Open(t.TempDir(), func(txn Txn) Txn { return txn })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulates Reopen, which could not open database with either options
+	db.mu.Lock()
+	db.stopGCRepeater()
+	err = markClosed(db.badgerdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.badgerdb.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.restore(db.badgerdb.Opts().WithValueLogFileSize(1), errors.New("new options rejected"))
+	db.mu.Unlock()
+	if !errors.Is(err, ErrDBUnusable) {
+		t.Fatalf("restore returned %v, want ErrDBUnusable", err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		return nil
+	})
+	if !errors.Is(err, ErrDBUnusable) {
+		t.Fatalf("View returned %v, want ErrDBUnusable", err)
+	}
+	err = db.Reopen(badger.DefaultOptions(""))
+	if !errors.Is(err, ErrDBUnusable) {
+		t.Fatalf("Reopen returned %v, want ErrDBUnusable", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
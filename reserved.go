@@ -0,0 +1,108 @@
+package instorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Version of storage format written by this package. Databases written with
+// newer format version are refused by Open. Databases written with older format
+// version are refused too, unless WithRewriteKeys is passed.
+const FormatVersion uint64 = 2
+
+// Encoded namespace names never start with \x00 byte, so keys starting with it
+// never collide with namespace keys
+var reservedPrefix = []byte("\x00instorage\x00")
+
+func reservedKey(name string) []byte {
+	return append(reservedPrefix[:len(reservedPrefix):len(reservedPrefix)], name...)
+}
+
+var formatVersionKey = reservedKey("format_version")
+
+// Stores FormatVersion in database, if it is not stored yet. Rewrites keys of
+// database written with older format version, if rewriteKeys is true. Returns
+// ErrIncompatibleFormat if database was written with newer format version, or
+// with older one and its keys can not be rewritten. Databases with data, but
+// without stored format version, were written before it was stored, so they
+// have format version 1.
+func checkFormatVersion(badgerdb *badger.DB, rewriteKeys bool) error {
+	stored := false
+	empty := true
+	version := FormatVersion
+
+	err := badgerdb.View(func(badgertxn *badger.Txn) error {
+		item, err := badgertxn.Get(formatVersionKey)
+		if err != nil {
+			if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			iteratorOptions := badger.DefaultIteratorOptions
+			iteratorOptions.PrefetchValues = false
+			it := badgertxn.NewIterator(iteratorOptions)
+			defer it.Close()
+
+			it.Rewind()
+			empty = !it.Valid()
+
+			return nil
+		}
+
+		stored = true
+
+		return item.Value(func(versionb []byte) error {
+			if len(versionb) != 8 {
+				return fmt.Errorf("%w: malformed format version", ErrIncompatibleFormat)
+			}
+
+			version = binary.BigEndian.Uint64(versionb)
+			if version > FormatVersion {
+				return fmt.Errorf("%w: database format version %v is newer than supported %v", ErrIncompatibleFormat, version, FormatVersion)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("checkFormatVersion: %w", err)
+	}
+	if !stored && !empty {
+		version = 1
+	}
+
+	if version < FormatVersion {
+		if !rewriteKeys || badgerdb.Opts().ReadOnly {
+			return fmt.Errorf("checkFormatVersion: %w: database format version %v is older than supported %v, open it with WithRewriteKeys to migrate", ErrIncompatibleFormat, version, FormatVersion)
+		}
+
+		err = rewriteLegacyKeys(badgerdb)
+		if err != nil {
+			return fmt.Errorf("checkFormatVersion: %w", err)
+		}
+
+		return nil
+	}
+
+	if stored || badgerdb.Opts().ReadOnly {
+		return nil
+	}
+
+	err = storeFormatVersion(badgerdb)
+	if err != nil {
+		return fmt.Errorf("checkFormatVersion: %w", err)
+	}
+
+	return nil
+}
+
+// Stores FormatVersion in database. Must be called after all keys are deleted,
+// otherwise data written after that is taken for format version 1 on Open.
+func storeFormatVersion(badgerdb *badger.DB) error {
+	return badgerdb.Update(func(badgertxn *badger.Txn) error {
+		return badgertxn.Set(formatVersionKey, uint64Bytes(FormatVersion))
+	})
+}
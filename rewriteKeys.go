@@ -0,0 +1,224 @@
+package instorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Makes Open rewrite keys of database written with format version 1, where
+// namespace names were followed by \x00 separator, to the current layout with
+// length prefixed names. It is done once, after that database is opened without
+// rewriting. If rewriting is interrupted, it is resumed on the next Open with
+// this option. Databases written with format version 1 can not be opened
+// without this option.
+func WithRewriteKeys() Option {
+	return func(dbopts *dbOptions) {
+		dbopts.rewriteKeys = true
+	}
+}
+
+// Stored while keys are rewritten, so interrupted rewriting may be resumed
+var rewritingKeysKey = reservedKey("rewriting_keys")
+
+// Kinds of reserved keys, which contain namespace name right after kind
+var namedReservedKinds = []string{"index\x00", "schema\x00", "revision\x00", "idempotency\x00", "namespace_id\x00"}
+
+// Rewrites keys of format version 1 to the current layout in batches, keeping
+// their expiration and user metadata, and stores FormatVersion after all keys
+// are rewritten
+func rewriteLegacyKeys(badgerdb *badger.DB) error {
+	resumed := false
+	err := badgerdb.Update(func(badgertxn *badger.Txn) error {
+		_, err := badgertxn.Get(rewritingKeysKey)
+		if err == nil {
+			resumed = true
+			return nil
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		return badgertxn.Set(rewritingKeysKey, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("rewriteLegacyKeys: %w", err)
+	}
+
+	wb := badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err = badgerdb.View(func(badgertxn *badger.Txn) error {
+		iteratorOptions := badger.DefaultIteratorOptions
+		iteratorOptions.PrefetchValues = false
+		it := badgertxn.NewIterator(iteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+
+			var newKey, valueb []byte
+			if bytes.HasPrefix(item.Key(), expiryIndexPrefix) {
+				var err error
+				newKey, valueb, err = rewrittenExpiryEntry(item, resumed)
+				if err != nil {
+					return err
+				}
+			} else {
+				newKey = rewrittenKey(item.Key(), resumed)
+				if newKey != nil {
+					var err error
+					valueb, err = item.ValueCopy(nil)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if newKey == nil {
+				continue
+			}
+
+			entry := badger.NewEntry(newKey, valueb).WithMeta(item.UserMeta())
+			entry.ExpiresAt = item.ExpiresAt()
+
+			err := wb.SetEntry(entry)
+			if err != nil {
+				return err
+			}
+			err = wb.Delete(item.KeyCopy(nil))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("rewriteLegacyKeys: %w", err)
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return fmt.Errorf("rewriteLegacyKeys: %w", err)
+	}
+
+	err = badgerdb.Update(func(badgertxn *badger.Txn) error {
+		err := badgertxn.Set(formatVersionKey, uint64Bytes(FormatVersion))
+		if err != nil {
+			return err
+		}
+
+		return badgertxn.Delete(rewritingKeysKey)
+	})
+	if err != nil {
+		return fmt.Errorf("rewriteLegacyKeys: %w", err)
+	}
+
+	return nil
+}
+
+// Returns key of format version 1 rewritten to the current layout, or nil if
+// it does not contain namespace name. If resumed is true, keys already
+// rewritten are not rewritten again.
+func rewrittenKey(key []byte, resumed bool) []byte {
+	switch {
+	case bytes.HasPrefix(key, reservedPrefix):
+		for _, kind := range namedReservedKinds {
+			kindPrefix := reservedKey(kind)
+			if bytes.HasPrefix(key, kindPrefix) {
+				return rewrittenName(key, len(kindPrefix), resumed)
+			}
+		}
+		return nil
+	case bytes.HasPrefix(key, tenantKeyPrefix):
+		offset := 0
+		for bytes.HasPrefix(key[offset:], tenantKeyPrefix) {
+			if len(key)-offset < len(tenantKeyPrefix)+4 {
+				return nil
+			}
+			offset += len(tenantKeyPrefix) + 4 + int(binary.BigEndian.Uint32(key[offset+len(tenantKeyPrefix):]))
+			if offset > len(key) {
+				return nil
+			}
+		}
+
+		rest := rewrittenKey(key[offset:], resumed)
+		if rest == nil {
+			return nil
+		}
+		return joinKey(key[:offset], rest)
+	case len(key) > 0 && key[0] == 0x00:
+		// Interned namespaces are stored under IDs instead of names
+		return nil
+	default:
+		return rewrittenName(key, 0, resumed)
+	}
+}
+
+// Returns key with name starting at offset and ending before \x00 separator or
+// at the end of key replaced with encoded name, or nil if name is empty
+func rewrittenName(key []byte, offset int, resumed bool) []byte {
+	if resumed && isEncodedName(key[offset:]) {
+		return nil
+	}
+
+	end := bytes.IndexByte(key[offset:], 0x00)
+	if end < 0 {
+		end = len(key)
+	} else {
+		end += offset
+	}
+	if end == offset {
+		return nil
+	}
+
+	encoded := encodeNamespaceName(string(key[offset:end]))
+	newKey := make([]byte, 0, len(key)+len(encoded)-(end-offset))
+	newKey = append(newKey, key[:offset]...)
+	newKey = append(newKey, encoded...)
+	return append(newKey, key[end:]...)
+}
+
+// Reports whether b starts with encoded name followed by \x00 separator or
+// nothing
+func isEncodedName(b []byte) bool {
+	nameLen, n := binary.Uvarint(b)
+	if n <= 0 || nameLen == 0 || uint64(len(b)-n) < nameLen {
+		return false
+	}
+
+	end := n + int(nameLen)
+	return end == len(b) || b[end] == 0x00
+}
+
+// Returns expiry index entry with stored key rewritten to the current layout
+// and encoded key offset moved accordingly, or nil key if stored key is not
+// rewritten
+func rewrittenExpiryEntry(item *badger.Item, resumed bool) (newKey []byte, valueb []byte, err error) {
+	headerLen := len(expiryIndexPrefix) + 8
+	if len(item.Key()) < headerLen {
+		return nil, nil, nil
+	}
+
+	storedKey := item.Key()[headerLen:]
+	newStoredKey := rewrittenKey(storedKey, resumed)
+	if newStoredKey == nil {
+		return nil, nil, nil
+	}
+
+	valueb, err = item.ValueCopy(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(valueb) < 4 {
+		return nil, nil, nil
+	}
+
+	keyOffset := int(binary.BigEndian.Uint32(valueb)) + len(newStoredKey) - len(storedKey)
+	valueb = append(uint32Bytes(uint32(keyOffset)), valueb[4:]...)
+
+	return joinKey(item.Key()[:headerLen], newStoredKey), valueb, nil
+}
@@ -0,0 +1,157 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Writes pairs to badger database in dir, as they were stored by format
+// version 1
+func writeLegacyDB(t *testing.T, dir string, pairs map[string][]byte) {
+	t.Helper()
+
+	badgerdb, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer badgerdb.Close()
+
+	err = badgerdb.Update(func(badgertxn *badger.Txn) error {
+		for key, valueb := range pairs {
+			err := badgertxn.Set([]byte(key), valueb)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustEncodeGob(t *testing.T, data any) []byte {
+	t.Helper()
+
+	b, err := encodeGob(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Checks that namespace users stores passed values and revision
+func checkRewrittenUsers(t *testing.T, db *DB[Txn], values map[string]int, revision uint64) {
+	t.Helper()
+
+	err := db.View(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, int](txn, "users")
+		for key, want := range values {
+			value, ok, err := nsm.Get(key)
+			if err != nil {
+				return err
+			}
+			if !ok || value != want {
+				t.Errorf("Get(%q) = %v, %v, expected %v", key, value, ok, want)
+			}
+		}
+
+		count := 0
+		err := nsm.Iter(func(key string, value int) (bool, error) {
+			count++
+			return false, nil
+		})
+		if err != nil {
+			return err
+		}
+		if count != len(values) {
+			t.Errorf("namespace has %v pairs, expected %v", count, len(values))
+		}
+
+		stored, err := nsm.Revision()
+		if err != nil {
+			return err
+		}
+		if stored != revision {
+			t.Errorf("revision is %v, expected %v", stored, revision)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRewriteLegacyKeys(t *testing.T) {
+	dir := t.TempDir()
+	revisionb, _ := encodePrimitive(uint64(3))
+	writeLegacyDB(t, dir, map[string][]byte{
+		"users\x00" + string(mustEncodeGob(t, "alice")): mustEncodeGob(t, 1),
+		"users\x00" + string(mustEncodeGob(t, "bob")):   mustEncodeGob(t, 2),
+		string(reservedKey("revision\x00users")):        revisionb,
+	})
+
+	_, err := Open(dir, func(txn Txn) Txn { return txn })
+	if !errors.Is(err, ErrIncompatibleFormat) {
+		t.Fatalf("opening legacy database without WithRewriteKeys returned %v, expected ErrIncompatibleFormat", err)
+	}
+
+	db, err := Open(dir, func(txn Txn) Txn { return txn }, WithRewriteKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	checkRewrittenUsers(t, db, map[string]int{"alice": 1, "bob": 2}, 3)
+}
+
+func TestRewriteLegacyKeysResumes(t *testing.T) {
+	dir := t.TempDir()
+	// Interrupted rewriting has already rewritten bob, but not alice
+	writeLegacyDB(t, dir, map[string][]byte{
+		string(rewritingKeysKey):                                                      nil,
+		"users\x00" + string(mustEncodeGob(t, "alice")):                               mustEncodeGob(t, 1),
+		string(addPrefixToKey(encodeNamespaceName("users"), mustEncodeGob(t, "bob"))): mustEncodeGob(t, 2),
+	})
+
+	db, err := Open(dir, func(txn Txn) Txn { return txn }, WithRewriteKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	checkRewrittenUsers(t, db, map[string]int{"alice": 1, "bob": 2}, 0)
+
+	err = db.View(func(txn Txn) error {
+		_, err := txn.badgertxn.Get(rewritingKeysKey)
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			t.Errorf("rewriting marker is left after rewriting with %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsEncodedName(t *testing.T) {
+	for _, test := range []struct {
+		b    string
+		want bool
+	}{
+		{"\x05users", true},
+		{"\x05users\x00key", true},
+		{"users\x00key", false},
+		{"\x05use", false},
+		{"\x05usersx", false},
+		{"\x00", false},
+		{"", false},
+	} {
+		got := isEncodedName([]byte(test.b))
+		if got != test.want {
+			t.Errorf("isEncodedName(%q) = %v, expected %v", test.b, got, test.want)
+		}
+	}
+}
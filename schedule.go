@@ -0,0 +1,63 @@
+package instorage
+
+import (
+	"time"
+)
+
+// Calls task every interval until returned cancel is called or database is
+// closed. Close waits for running task to finish, so task may use db. Errors
+// returned by task are written to badger logger, task is called again on the
+// next interval. db must not be closed.
+func (db *DB[TxnAPIT]) Schedule(interval time.Duration, task func() error) (cancel func()) {
+	if interval <= 0 {
+		panic("interval must be positive")
+	}
+	if task == nil {
+		panic("task must not be nil")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed || db.refs == 0 {
+		panic("db must not be closed")
+	}
+	db.lastTaskID++
+	id := db.lastTaskID
+
+	handle := db.dbHandle
+	handle.tasks.StartRepeater(id, interval, func() {
+		err := task()
+		if err != nil {
+			handle.mu.RLock()
+			logger := handle.badgerdb.Opts().Logger
+			handle.mu.RUnlock()
+
+			if logger != nil {
+				logger.Errorf("instorage: scheduled task: %v", err)
+			}
+		}
+	})
+
+	return func() {
+		handle.tasks.StopRepeater(id)
+	}
+}
+
+// Releases db like release and, if it was the last user of database, stops
+// tasks of Schedule. Tasks may use database, so they are stopped without
+// holding mu. Returns true with mu locked, or false with mu unlocked.
+func (db *DB[TxnAPIT]) releaseAndStopTasks() bool {
+	db.mu.Lock()
+	last := db.release()
+	db.mu.Unlock()
+
+	if !last {
+		return false
+	}
+
+	db.tasks.StopAllRepeaters()
+	db.mu.Lock()
+
+	return true
+}
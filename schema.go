@@ -0,0 +1,267 @@
+package instorage
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Schemas of values stored in namespaces are kept under reserved keys with
+// encoded namespace names, so key of one namespace is never a prefix of another
+func schemaKey(name string) []byte {
+	return addPrefixToKey(reservedKey("schema\x00"+string(encodeNamespaceName(name))), nil)
+}
+
+// By default Set records schema of ValueT in namespace on the first write, and
+// reads and writes return ErrSchemaDrift, when ValueT is incompatible with
+// recorded schema. Schema describes only what gob relies on: exported struct
+// fields by their names, and kinds of their values as gob transmits them, so
+// renaming types, reordering, adding and removing fields, or changing int to
+// int64 are compatible, while changing type of existing field, for example
+// from int to string, is not. Fields added later are recorded by the next
+// write. Check is skipped, when WithDecodeFallback is set, because fallback
+// handles values of other types.
+//
+// Disables this check, for example for namespaces intentionally storing values
+// of different types. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithoutSchemaCheck() *NamespaceMultiple[KeyT, ValueT] {
+	nsm.schema = nil
+	return nsm
+}
+
+// Schemas are stored as sorted lines of field paths and wire kinds after this
+// header. Values without it were recorded by earlier versions and are ignored.
+const gobSchemaHeader = "gob_schema\n"
+
+// Schemas already computed, by reflect.Type
+var gobSchemas sync.Map
+
+// Returns schema of type t as gob transmits it: lines of field paths and
+// kinds of their values
+func gobSchema(t reflect.Type) []byte {
+	if schema, ok := gobSchemas.Load(t); ok {
+		return schema.([]byte)
+	}
+
+	var lines []string
+	describeWireType(&lines, ".", t, map[reflect.Type]bool{})
+	sort.Strings(lines)
+	schema := []byte(gobSchemaHeader + strings.Join(lines, "\n"))
+
+	gobSchemas.Store(t, schema)
+	return schema
+}
+
+var (
+	gobEncoderType       = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+	binaryMarshalerType  = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	textMarshalerType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	externalEncoderKinds = []struct {
+		t    reflect.Type
+		kind string
+	}{
+		{gobEncoderType, "gob_encoder"},
+		{binaryMarshalerType, "binary_marshaler"},
+		{textMarshalerType, "text_marshaler"},
+	}
+)
+
+// Appends "path kind" lines describing type t at path and its elements.
+// Pointers are dereferenced and sized numeric kinds are merged, as gob does.
+func describeWireType(lines *[]string, path string, t reflect.Type, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Interface values are sent with their concrete types
+	if t.Kind() == reflect.Interface {
+		*lines = append(*lines, path+" interface")
+		return
+	}
+	for _, external := range externalEncoderKinds {
+		if t.Implements(external.t) || reflect.PointerTo(t).Implements(external.t) {
+			*lines = append(*lines, path+" "+external.kind)
+			return
+		}
+	}
+
+	// Recursive types are described only once
+	if visiting[t] {
+		*lines = append(*lines, path+" recursive")
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	kind := ""
+	switch t.Kind() {
+	case reflect.Bool:
+		kind = "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		kind = "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		kind = "uint"
+	case reflect.Float32, reflect.Float64:
+		kind = "float"
+	case reflect.Complex64, reflect.Complex128:
+		kind = "complex"
+	case reflect.String:
+		kind = "string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			kind = "bytes"
+			break
+		}
+		kind = "slice"
+		describeWireType(lines, path+"[]", t.Elem(), visiting)
+	case reflect.Array:
+		kind = "array" + strconv.Itoa(t.Len())
+		describeWireType(lines, path+"[]", t.Elem(), visiting)
+	case reflect.Map:
+		kind = "map"
+		describeWireType(lines, path+"[key]", t.Key(), visiting)
+		describeWireType(lines, path+"[elem]", t.Elem(), visiting)
+	case reflect.Struct:
+		kind = "struct"
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			// Gob skips unexported fields, channels and functions
+			if !field.IsExported() || field.Type.Kind() == reflect.Chan || field.Type.Kind() == reflect.Func {
+				continue
+			}
+			describeWireType(lines, strings.TrimSuffix(path, ".")+"."+field.Name, field.Type, visiting)
+		}
+	default:
+		kind = t.Kind().String()
+	}
+
+	*lines = append(*lines, path+" "+kind)
+}
+
+// Parses schema into kinds by field paths. Returns ok == false, if schema was
+// recorded by earlier versions.
+func parseGobSchema(schema []byte) (kinds map[string]string, ok bool) {
+	if !bytes.HasPrefix(schema, []byte(gobSchemaHeader)) {
+		return nil, false
+	}
+
+	kinds = map[string]string{}
+	for _, line := range strings.Split(string(schema[len(gobSchemaHeader):]), "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		kinds[line[:i]] = line[i+1:]
+	}
+
+	return kinds, true
+}
+
+// Stored schema of namespace cached in dbState
+type cachedSchema struct {
+	stored []byte
+	// Schemas of value types found compatible with stored one, which need no
+	// merging, by schemas
+	compatible sync.Map
+}
+
+// Drops cached schemas, which schema keys match, after they are deleted by
+// DropAll, DropTenant or LoadBackup
+func (state *dbState) forgetSchemasIf(match func(key string) bool) {
+	state.schemas.Range(func(key, value any) bool {
+		if match(key.(string)) {
+			state.schemas.Delete(key)
+		}
+		return true
+	})
+}
+
+// Returns ErrSchemaDrift, if namespace stores values with schema incompatible
+// with ValueT. If write is true, schema of ValueT is merged into stored one,
+// when it has paths not recorded yet. Stored schemas are cached in dbState once
+// read, so each namespace is read from database only once.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) checkSchema(write bool) error {
+	if nsm.schema == nil || nsm.decodeFallback != nil || nsm.txn.state == nil {
+		return nil
+	}
+
+	key := nsm.txn.scopedKey(schemaKey(nsm.name))
+
+	var cached *cachedSchema
+	if value, ok := nsm.txn.state.schemas.Load(string(key)); ok {
+		cached = value.(*cachedSchema)
+	} else {
+		cached = &cachedSchema{}
+		item, err := nsm.txn.badgertxn.Get(key)
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		if err == nil {
+			cached.stored, err = item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+		}
+		nsm.txn.state.schemas.Store(string(key), cached)
+	}
+	if _, ok := cached.compatible.Load(string(nsm.schema)); ok {
+		return nil
+	}
+
+	storedKinds, ok := parseGobSchema(cached.stored)
+	if !ok {
+		storedKinds = map[string]string{}
+	}
+	kinds, _ := parseGobSchema(nsm.schema)
+
+	added := false
+	for path, kind := range kinds {
+		storedKind, ok := storedKinds[path]
+		if !ok {
+			added = true
+			continue
+		}
+		if storedKind != kind {
+			return fmt.Errorf("%w: `%v` stores %v as %v, but %v has %v", ErrSchemaDrift, nsm.name, path, storedKind, reflect.TypeOf((*ValueT)(nil)).Elem(), kind)
+		}
+	}
+	if !added {
+		cached.compatible.Store(string(nsm.schema), struct{}{})
+		return nil
+	}
+	if !write {
+		return nil
+	}
+
+	for path, kind := range kinds {
+		storedKinds[path] = kind
+	}
+	lines := make([]string, 0, len(storedKinds))
+	for path, kind := range storedKinds {
+		lines = append(lines, path+" "+kind)
+	}
+	sort.Strings(lines)
+	merged := []byte(gobSchemaHeader + strings.Join(lines, "\n"))
+
+	err := nsm.txn.badgertxn.Set(key, merged)
+	if err != nil {
+		return err
+	}
+	// Merged schema is compatible with stored one, so it is cached even if
+	// transaction is discarded. Paths missing in database are merged again
+	// after restart.
+	merging := &cachedSchema{stored: merged}
+	merging.compatible.Store(string(nsm.schema), struct{}{})
+	nsm.txn.state.schemas.Store(string(key), merging)
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+)
+
+type schemaV1 struct {
+	A int
+	B string
+}
+
+// Fields reordered, one added and A widened to int64
+type schemaV2 struct {
+	C bool
+	B string
+	A int64
+}
+
+// Type of B changed
+type schemaV3 struct {
+	A int
+	B []int
+}
+
+func TestSchemaCheckAllowsGobCompatibleChanges(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV1](txn, "values").Set(1, schemaV1{A: 1, B: "b"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, schemaV2](txn, "values")
+
+		value, ok, err := nsm.Get(1)
+		if err != nil {
+			return err
+		}
+		if !ok || value.A != 1 || value.B != "b" {
+			t.Errorf("Get returned %+v, %v", value, ok)
+		}
+
+		return nsm.Set(2, schemaV2{A: 2, B: "c", C: true})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaCheckRejectsChangedFieldType(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV1](txn, "values").Set(1, schemaV1{A: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, _, err := NewNamespaceMultiple[int, schemaV3](txn, "values").Get(1)
+		return err
+	})
+	if !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("Get returned %v, want ErrSchemaDrift", err)
+	}
+
+	// Without the option values are decoded as is
+	err = db.View(func(txn Txn) error {
+		_, _, err := NewNamespaceMultiple[int, schemaV2](txn, "values").Get(1)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaCheckRecordsAddedFields(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV1](txn, "values").Set(1, schemaV1{A: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV2](txn, "values").Set(2, schemaV2{C: true})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C was recorded as bool by the second write
+	type schemaV4 struct {
+		C string
+	}
+	err = db.View(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV4](txn, "values").Iter(func(key int, value schemaV4) (bool, error) {
+			return false, nil
+		})
+	})
+	if !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("Iter returned %v, want ErrSchemaDrift", err)
+	}
+}
+
+func TestSchemaCheckSkippedWithDecodeFallback(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV1](txn, "values").Set(1, schemaV1{A: 1, B: "old"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, schemaV3](txn, "values").
+			WithDecodeFallback(func(valueb []byte) (schemaV3, bool) {
+				old, err := decodeGob[schemaV1](valueb)
+				if err != nil {
+					return schemaV3{}, false
+				}
+				return schemaV3{A: old.A, B: []int{len(old.B)}}, true
+			})
+
+		value, ok, err := nsm.Get(1)
+		if err != nil {
+			return err
+		}
+		if !ok || value.A != 1 || len(value.B) != 1 || value.B[0] != 3 {
+			t.Errorf("Get returned %+v, %v", value, ok)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaCheckIsDefaultAndMayBeDisabled(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV1](txn, "values").Set(1, schemaV1{A: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, _, err := NewNamespaceMultiple[int, schemaV3](txn, "values").Get(2)
+		return err
+	})
+	if !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("reading with incompatible type returned %v, expected ErrSchemaDrift", err)
+	}
+
+	err = db.Update(func(txn Txn) error {
+		return NewNamespaceMultiple[int, schemaV3](txn, "values").WithoutSchemaCheck().Set(2, schemaV3{A: 2})
+	})
+	if err != nil {
+		t.Fatalf("writing with disabled check returned %v", err)
+	}
+}
+
+func TestSchemaCheckAfterDrop(t *testing.T) {
+	for _, tenant := range []string{"", "tenant"} {
+		db := openTestDB(t)
+
+		update := func(fn func(txn Txn) error) error {
+			return db.Update(func(txn Txn) error {
+				if tenant != "" {
+					txn = txn.Tenant(tenant)
+				}
+				return fn(txn)
+			})
+		}
+
+		err := update(func(txn Txn) error {
+			return NewNamespaceMultiple[int, schemaV1](txn, "values").Set(1, schemaV1{A: 1})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tenant == "" {
+			err = db.DropAll()
+		} else {
+			err = db.DropTenant(tenant)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Namespace is empty, so values of another type may be stored
+		err = update(func(txn Txn) error {
+			return NewNamespaceMultiple[int, schemaV3](txn, "values").Set(1, schemaV3{A: 1})
+		})
+		if err != nil {
+			t.Fatalf("writing to dropped namespace returned %v", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package instorage
+
+import (
+	"iter"
+)
+
+// Returns iterator over key-value pairs in key order for range-over-func
+// loops. Iteration stops on the first error, which is not reported, so use
+// SeqErr or Iter when errors matter.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) Seq() iter.Seq2[KeyT, ValueT] {
+	seq, _ := nsm.SeqErr()
+	return seq
+}
+
+// Same as Seq, but also returns function reporting error, which stopped the
+// last iteration, to be checked after loop
+func (nsm *NamespaceMultiple[KeyT, ValueT]) SeqErr() (seq iter.Seq2[KeyT, ValueT], errf func() error) {
+	var err error
+
+	seq = func(yield func(key KeyT, value ValueT) bool) {
+		err = nsm.Iter(func(key KeyT, value ValueT) (bool, error) {
+			return !yield(key, value), nil
+		})
+	}
+
+	return seq, func() error {
+		return err
+	}
+}
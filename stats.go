@@ -0,0 +1,172 @@
+package instorage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Database statistics returned by DB.Stats
+type Stats struct {
+	// Size of LSM tree files in bytes
+	LSMSize int64
+	// Size of value log files in bytes
+	ValueLogSize int64
+	Levels       []LevelStats
+	// False positive probability of bloom filters, set by
+	// WithBloomFalsePositive
+	BloomFalsePositive float64
+	// Number of NamespaceMultiple.Get calls since Open and number of them,
+	// which found no key. Bloom filters make misses cheap, so a high share of
+	// misses suggests lowering BloomFalsePositive.
+	Gets      int64
+	GetMisses int64
+}
+
+// Statistics of a single LSM tree level
+type LevelStats struct {
+	Level     int
+	NumTables int
+	// Size of level tables in bytes
+	Size int64
+	// Size in bytes, which level is compacted to
+	TargetSize int64
+	// Compaction priority of level, level is waiting for compaction when its
+	// score is 1 or greater
+	Score float64
+}
+
+// Returns number of levels waiting for compaction
+func (stats Stats) PendingCompactions() int {
+	pending := 0
+	for _, level := range stats.Levels {
+		if level.Score >= 1 {
+			pending++
+		}
+	}
+
+	return pending
+}
+
+// Returns current database statistics
+func (db *DB[TxnAPIT]) Stats() Stats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	stats := badgerStats(db.badgerdb)
+	stats.Gets = atomic.LoadInt64(&db.state.gets.total)
+	stats.GetMisses = atomic.LoadInt64(&db.state.gets.misses)
+
+	return stats
+}
+
+func badgerStats(badgerdb *badger.DB) Stats {
+	var stats Stats
+
+	stats.LSMSize, stats.ValueLogSize = badgerdb.Size()
+	stats.BloomFalsePositive = badgerdb.Opts().BloomFalsePositive
+
+	for _, level := range badgerdb.Levels() {
+		stats.Levels = append(stats.Levels, LevelStats{
+			Level:      level.Level,
+			NumTables:  level.NumTables,
+			Size:       level.Size,
+			TargetSize: level.TargetSize,
+			Score:      level.Score,
+		})
+	}
+
+	return stats
+}
+
+// Sets callback, which is called when number of levels waiting for compaction
+// reaches threshold, so heavy compaction can be reacted to, for example by
+// shedding load. Compaction backlog is checked every minute.
+func WithCompactionBacklogCallback(threshold int, onCompactionBacklog func(stats Stats)) Option {
+	return func(dbopts *dbOptions) {
+		dbopts.compactionBacklogThreshold = threshold
+		dbopts.onCompactionBacklog = onCompactionBacklog
+	}
+}
+
+func (dbopts *dbOptions) checkCompactionBacklog(badgerdb *badger.DB) {
+	if dbopts == nil || dbopts.onCompactionBacklog == nil {
+		return
+	}
+
+	stats := badgerStats(badgerdb)
+	if stats.PendingCompactions() >= dbopts.compactionBacklogThreshold {
+		dbopts.onCompactionBacklog(stats)
+	}
+}
+
+// Cumulative value log garbage collection statistics returned by DB.GCStats.
+// Counters are kept since Open.
+type GCStats struct {
+	// Number of garbage collection runs
+	Runs int64
+	// Number of runs, which rewrote a value log file
+	Rewrites int64
+	// Estimated number of bytes freed by rewrites, based on value log size
+	// reported by badger, which is updated with a delay
+	ReclaimedBytes int64
+	// Time of the last run, zero if there were no runs
+	LastRun time.Time
+}
+
+// Returns value log garbage collection statistics. Garbage collection runs on
+// Open and then every minute.
+func (db *DB[TxnAPIT]) GCStats() GCStats {
+	return db.gc.get()
+}
+
+type gcCounters struct {
+	mu    sync.Mutex
+	stats GCStats
+}
+
+// Runs value log garbage collection once and updates counters
+func (gc *gcCounters) run(badgerdb *badger.DB, discardRatio float64) {
+	_, vlogSizeBefore := badgerdb.Size()
+	err := badgerdb.RunValueLogGC(discardRatio)
+	_, vlogSizeAfter := badgerdb.Size()
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.stats.Runs++
+	gc.stats.LastRun = time.Now()
+	if err == nil {
+		gc.stats.Rewrites++
+		if vlogSizeBefore > vlogSizeAfter {
+			gc.stats.ReclaimedBytes += vlogSizeBefore - vlogSizeAfter
+		}
+	}
+}
+
+func (gc *gcCounters) get() GCStats {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.stats
+}
+
+// Counters of NamespaceMultiple.Get calls reported by DB.Stats, accessed
+// atomically
+type getCounters struct {
+	total  int64
+	misses int64
+}
+
+func (txn Txn) countGet(found bool) {
+	if txn.state == nil {
+		return
+	}
+
+	atomic.AddInt64(&txn.state.gets.total, 1)
+	if !found {
+		atomic.AddInt64(&txn.state.gets.misses, 1)
+	}
+}
@@ -0,0 +1,169 @@
+package instorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// Reads all key-value pairs of NamespaceMultiple with passed name using badger
+// Stream framework. Unlike Iter, pairs are read concurrently in many small
+// ranges, so no single read snapshot is held for the whole scan. Pairs are not
+// passed to cb in key order, but cb is never called concurrently.
+func StreamScan[TxnAPIT any, KeyT comparable, ValueT any](db *DB[TxnAPIT], namespace string, cb func(key KeyT, value ValueT) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefix := db.state.namespacePrefix(namespace)
+
+	stream := newNamespaceStream(db.badgerdb, namespace, prefix)
+	stream.Send = func(buf *z.Buffer) error {
+		kvlist, err := badger.BufferToKVList(buf)
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range kvlist.Kv {
+			keyPtr, err := decodeGob[KeyT](kv.Key[len(prefix):])
+			if err != nil {
+				return err
+			}
+			start := time.Now()
+			valuePtr, err := decodeValueGob[ValueT](kv.Value)
+			if err != nil {
+				return err
+			}
+			db.dbopts.observeDecode(namespace, len(kv.Value), start)
+
+			err = cb(*keyPtr, *valuePtr)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	err := stream.Orchestrate(context.Background())
+	if err != nil {
+		return fmt.Errorf("StreamScan `%v`: %w", namespace, err)
+	}
+
+	return nil
+}
+
+// Key-value pair with gob encoded key and value, as stored in database. Key
+// does not include namespace prefix.
+type RawKeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// Reads namespace with passed name using badger Stream framework, scanning it
+// in parallel on multiple goroutines. chooseKey may be nil to select all keys,
+// otherwise it is called concurrently. send receives batches of selected pairs
+// and is never called concurrently. If ordered == true, namespace is scanned
+// on single goroutine, so pairs are sent in key order.
+func (db *DB[TxnAPIT]) StreamNamespace(name string, chooseKey func(item *badger.Item) bool, send func(kvs []RawKeyValue) error, ordered bool) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefix := db.state.namespacePrefix(name)
+
+	stream := newNamespaceStream(db.badgerdb, name, prefix)
+	stream.ChooseKey = chooseKey
+	if ordered {
+		stream.NumGo = 1
+	}
+	stream.Send = func(buf *z.Buffer) error {
+		kvlist, err := badger.BufferToKVList(buf)
+		if err != nil {
+			return err
+		}
+
+		kvs := make([]RawKeyValue, 0, len(kvlist.Kv))
+		for _, kv := range kvlist.Kv {
+			kvs = append(kvs, RawKeyValue{
+				Key:   kv.Key[len(prefix):],
+				Value: kv.Value,
+			})
+		}
+
+		return send(kvs)
+	}
+
+	err := stream.Orchestrate(context.Background())
+	if err != nil {
+		return fmt.Errorf("StreamNamespace `%v`: %w", name, err)
+	}
+
+	return nil
+}
+
+func newNamespaceStream(badgerdb *badger.DB, namespace string, prefix []byte) *badger.Stream {
+	stream := badgerdb.NewStream()
+	stream.Prefix = prefix
+	stream.LogPrefix = "instorage.Stream `" + namespace + "`"
+
+	return stream
+}
+
+// Writes all key-value pairs of namespace to w as they are iterated, without
+// collecting them in memory. Pairs are written with encode, or as JSON objects
+// of KeyValue separated by newlines, if encode is nil. If w has Flush method,
+// like http.ResponseWriter implementing http.Flusher or bufio.Writer, it is
+// called after every flushEvery pairs and at the end, so client receives pairs
+// while namespace is iterated. Iter itself does not buffer pairs, so the same
+// may be done in its viewer. Returns number of written pairs.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) StreamTo(w io.Writer, flushEvery int, encode func(w io.Writer, key KeyT, value ValueT) error) (written int, err error) {
+	if encode == nil {
+		jsonEncoder := json.NewEncoder(w)
+		encode = func(w io.Writer, key KeyT, value ValueT) error {
+			return jsonEncoder.Encode(KeyValue[KeyT, ValueT]{Key: key, Value: value})
+		}
+	}
+
+	err = nsm.Iter(func(key KeyT, value ValueT) (bool, error) {
+		err := encode(w, key, value)
+		if err != nil {
+			return true, err
+		}
+		written++
+
+		if flushEvery > 0 && written%flushEvery == 0 {
+			err = flushWriter(w)
+			if err != nil {
+				return true, err
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return written, fmt.Errorf("StreamTo `%v`: %w", nsm.name, err)
+	}
+
+	err = flushWriter(w)
+	if err != nil {
+		return written, fmt.Errorf("StreamTo `%v`: %w", nsm.name, err)
+	}
+
+	return written, nil
+}
+
+// Calls Flush method of w, if it has one
+func flushWriter(w io.Writer) error {
+	switch flusher := w.(type) {
+	case interface{ Flush() error }:
+		return flusher.Flush()
+	case interface{ Flush() }:
+		flusher.Flush()
+	}
+
+	return nil
+}
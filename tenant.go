@@ -0,0 +1,47 @@
+package instorage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tenant keys start with this prefix followed by length prefixed tenant ID. It
+// does not collide with reservedPrefix, internedPrefix and namespace names.
+var tenantKeyPrefix = []byte("\x00\x02")
+
+func tenantPrefix(id string) []byte {
+	prefix := make([]byte, 0, len(tenantKeyPrefix)+4+len(id))
+	prefix = append(prefix, tenantKeyPrefix...)
+	prefix = append(prefix, uint32Bytes(uint32(len(id)))...)
+	return append(prefix, id...)
+}
+
+// Returns transaction, which namespaces store their data separately for
+// tenant with passed id. Namespaces with the same name but different tenants
+// never see each other's data, whatever ids and names are. Calling Tenant on
+// already scoped transaction scopes it further. Data of a tenant may be
+// removed with DB.DropTenant.
+func (txn Txn) Tenant(id string) Txn {
+	scoped := txn
+	scoped.tenantPrefix = joinKey(txn.tenantPrefix, tenantPrefix(id))
+	return scoped
+}
+
+// Deletes data of all namespaces of tenant with passed id
+func (db *DB[TxnAPIT]) DropTenant(id string) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefix := tenantPrefix(id)
+	err := db.badgerdb.DropPrefix(prefix)
+	if err != nil {
+		return fmt.Errorf("DropTenant `%v`: %w", id, err)
+	}
+	dropped := func(key string) bool {
+		return strings.HasPrefix(key, string(prefix))
+	}
+	db.state.forgetSchemasIf(dropped)
+	db.state.forgetTypeDescriptorsIf(dropped)
+
+	return nil
+}
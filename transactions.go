@@ -2,8 +2,13 @@ package instorage
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
@@ -11,6 +16,102 @@ import (
 // Transaction session used by NamespaceSingle and NamespaceMultiple
 type Txn struct {
 	badgertxn *badger.Txn
+	badgerdb  *badger.DB
+	dbopts    *dbOptions
+	state     *dbState
+	// Cancels long running operations, when done
+	ctx context.Context
+	// Prepended to all keys of namespaces created with this transaction, set
+	// by Tenant
+	tenantPrefix []byte
+	// Hooks registered by BeforeCommit, shared by copies of Txn. Nil for
+	// read-only transactions.
+	beforeCommit *[]func() error
+}
+
+// Panics if name is not valid namespace name
+func (txn Txn) validateNamespaceName(name string) {
+	if name == "" {
+		panic("name must not be empty")
+	}
+}
+
+// Returns prefix of keys stored in namespace with passed name
+func (txn Txn) namespacePrefix(name string) []byte {
+	return txn.scopedKey(txn.state.namespacePrefix(name))
+}
+
+// Returns key of NamespaceSingle with passed name
+func (txn Txn) singleKey(name string) []byte {
+	return txn.scopedKey(encodeNamespaceName(name))
+}
+
+// Prepends tenant prefix to key, if transaction is scoped with Tenant
+func (txn Txn) scopedKey(key []byte) []byte {
+	if txn.tenantPrefix == nil {
+		return key
+	}
+
+	return joinKey(txn.tenantPrefix, key)
+}
+
+// Returns database version this transaction reads at. Changes committed after
+// this transaction started have greater versions.
+func (txn Txn) ReadVersion() uint64 {
+	return txn.badgertxn.ReadTs()
+}
+
+// Registers hook called after updater returns without error, right before
+// transaction is committed, for example to validate invariants across
+// namespaces written by updater. Hooks are called in order they were
+// registered and may use namespaces of transaction. If hook returns error,
+// the following hooks are not called and transaction is discarded. Panics if
+// transaction is read-only.
+func (txn Txn) BeforeCommit(hook func() error) {
+	if txn.beforeCommit == nil {
+		panic("BeforeCommit: transaction is read-only")
+	}
+
+	*txn.beforeCommit = append(*txn.beforeCommit, hook)
+}
+
+// Calls hooks registered by BeforeCommit, including ones registered by hooks
+func (txn Txn) runBeforeCommit() error {
+	for i := 0; i < len(*txn.beforeCommit); i++ {
+		err := (*txn.beforeCommit)[i]()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns ErrTxnTimeout if transaction deadline is exceeded
+func (txn Txn) checkContext() error {
+	if txn.ctx == nil {
+		return nil
+	}
+
+	err := txn.ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTxnTimeout
+	}
+
+	return err
+}
+
+func (txn Txn) now() time.Time {
+	if txn.dbopts == nil {
+		return time.Now()
+	}
+
+	return txn.dbopts.clock()
+}
+
+func (txn Txn) isExpired(item *badger.Item) bool {
+	expiresAt := item.ExpiresAt()
+	return expiresAt != 0 && expiresAt <= uint64(txn.now().Unix())
 }
 
 func encodeGob(data any) ([]byte, error) {
@@ -25,18 +126,61 @@ func encodeGob(data any) ([]byte, error) {
 
 func decodeGob[DataT any](b []byte) (dataPtr *DataT, err error) {
 	dataPtr = new(DataT)
-	err = gob.NewDecoder(bytes.NewReader(b)).Decode(dataPtr)
+	r := bytes.NewReader(b)
+	err = gob.NewDecoder(r).Decode(dataPtr)
 	if err != nil {
 		return dataPtr, fmt.Errorf("decodeGob: %w", err)
 	}
+	if r.Len() != 0 {
+		return dataPtr, fmt.Errorf("decodeGob: %w: %v of %v bytes left unread", ErrTrailingData, r.Len(), len(b))
+	}
 
 	return dataPtr, nil
 }
 
+// Values of zero-size types, like struct{}, are stored as empty bytes by
+// NamespaceMultiple, so they take no space. Encodes value as gob otherwise.
+func encodeValueGob[ValueT any](value ValueT) ([]byte, error) {
+	if reflect.TypeOf((*ValueT)(nil)).Elem().Size() == 0 {
+		return nil, nil
+	}
+
+	return encodeGob(value)
+}
+
+// Decodes value encoded by encodeValueGob
+func decodeValueGob[ValueT any](valueb []byte) (*ValueT, error) {
+	if reflect.TypeOf((*ValueT)(nil)).Elem().Size() == 0 {
+		return new(ValueT), nil
+	}
+
+	return decodeGob[ValueT](valueb)
+}
+
+func checkValueSize(valueb []byte, maxValueSize int) error {
+	if maxValueSize > 0 && len(valueb) > maxValueSize {
+		return fmt.Errorf("%w: %v bytes exceeds limit of %v bytes", ErrValueTooLarge, len(valueb), maxValueSize)
+	}
+
+	return nil
+}
+
+// Encodes namespace name as uvarint length followed by name bytes, so encoded
+// name is never a prefix of another one, whatever bytes names contain. First
+// byte of encoded non-empty name is never \x00.
+func encodeNamespaceName(name string) []byte {
+	encoded := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(name))
+	n := binary.PutUvarint(encoded, uint64(len(name)))
+	return append(encoded[:n], name...)
+}
+
 func addPrefixToKey(prefix []byte, key []byte) []byte {
 	return bytes.Join([][]byte{prefix, key}, []byte{0x00})
 }
 
-func removePrefixFromKey(prefix []byte, key []byte) []byte {
-	return key[len(prefix)+1:]
+// Joins prefix and key into a new slice
+func joinKey(prefix []byte, key []byte) []byte {
+	joined := make([]byte, 0, len(prefix)+len(key))
+	joined = append(joined, prefix...)
+	return append(joined, key...)
 }
@@ -0,0 +1,239 @@
+package instorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Makes values be stored without gob type description, which gob writes before
+// the first value of every stream, so every value stored by Set repeats it.
+// Description is stored once per namespace under reserved key instead, and
+// values are encoded and decoded as continuation of its stream. It saves a lot
+// of space for small struct values, see BenchmarkSharedTypeDescriptor. Gob
+// numbers types in order they are first used by process, so one description
+// is stored for each distinct numbering.
+// Values stored this way may be read only by NamespaceMultiple with this
+// option, not by StreamScan. Returns nsm.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) WithSharedTypeDescriptor() *NamespaceMultiple[KeyT, ValueT] {
+	nsm.sharedDescriptor = true
+	return nsm
+}
+
+// Returns prefix of keys of type descriptions stored for namespace with passed
+// name, which are followed by uvarint index of description
+func typeDescriptorsPrefix(name string) []byte {
+	return addPrefixToKey(reservedKey("type_descriptor\x00"+string(encodeNamespaceName(name))), nil)
+}
+
+// Gob streams encoding zero values of types, by reflect.Type. Type description
+// is written before zero value, so bytes of value written after them do not
+// contain it.
+var gobPreambles sync.Map
+
+// Returns fresh encoder writing to buf, which has already written type
+// description of ValueT, and bytes written by it
+func primedEncoder[ValueT any](buf *bytes.Buffer) (*gob.Encoder, []byte, error) {
+	encoder := gob.NewEncoder(buf)
+	err := encoder.Encode(new(ValueT))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := reflect.TypeOf((*ValueT)(nil)).Elem()
+	if preamble, ok := gobPreambles.Load(t); ok {
+		return encoder, preamble.([]byte), nil
+	}
+
+	preamble := append([]byte(nil), buf.Bytes()...)
+	gobPreambles.Store(t, preamble)
+
+	return encoder, preamble, nil
+}
+
+// Returned by encodeWithSharedDescriptor with write == false, when type
+// description of value is not stored in namespace yet, so no stored value may
+// be equal to it
+var errTypeDescriptorNotStored = errors.New("type description is not stored")
+
+// Encodes value as continuation of gob stream with type description stored
+// for namespace, prefixed with uvarint index of the description. Description
+// is stored, if it is not stored yet and write is true.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) encodeWithSharedDescriptor(value ValueT, write bool) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	encoder, preamble, err := primedEncoder[ValueT](buf)
+	if err != nil {
+		return nil, fmt.Errorf("encodeWithSharedDescriptor: %w", err)
+	}
+
+	index, err := nsm.typeDescriptorIndex(preamble, write)
+	if err != nil {
+		return nil, fmt.Errorf("encodeWithSharedDescriptor: %w", err)
+	}
+
+	buf.Reset()
+	indexb := make([]byte, binary.MaxVarintLen64)
+	buf.Write(indexb[:binary.PutUvarint(indexb, index)])
+
+	err = encoder.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("encodeWithSharedDescriptor: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decodes value encoded by encodeWithSharedDescriptor
+func (nsm *NamespaceMultiple[KeyT, ValueT]) decodeWithSharedDescriptor(valueb []byte) (*ValueT, error) {
+	index, n := binary.Uvarint(valueb)
+	if n <= 0 {
+		return nil, fmt.Errorf("decodeWithSharedDescriptor: %w", errors.New("malformed type description index"))
+	}
+
+	preamble, err := nsm.typeDescriptor(index)
+	if err != nil {
+		return nil, fmt.Errorf("decodeWithSharedDescriptor: %w", err)
+	}
+
+	r := bytes.NewReader(valueb[n:])
+	decoder := gob.NewDecoder(io.MultiReader(bytes.NewReader(preamble), r))
+	err = decoder.Decode(new(ValueT))
+	if err != nil {
+		return nil, fmt.Errorf("decodeWithSharedDescriptor: %w", err)
+	}
+
+	valuePtr := new(ValueT)
+	err = decoder.Decode(valuePtr)
+	if err != nil {
+		return nil, fmt.Errorf("decodeWithSharedDescriptor: %w", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("decodeWithSharedDescriptor: %w: %v of %v bytes left unread", ErrTrailingData, r.Len(), len(valueb))
+	}
+
+	return valuePtr, nil
+}
+
+// Returns index of stored type description equal to preamble. If it is not
+// stored yet, it is stored under the next index when write is true, otherwise
+// errTypeDescriptorNotStored is returned. Descriptions are cached in dbState
+// once read.
+func (nsm *NamespaceMultiple[KeyT, ValueT]) typeDescriptorIndex(preamble []byte, write bool) (uint64, error) {
+	prefix := nsm.txn.scopedKey(typeDescriptorsPrefix(nsm.name))
+
+	cacheKey := string(prefix) + string(preamble)
+	if index, ok := nsm.txn.state.typeDescriptorIndexes.Load(cacheKey); ok {
+		return index.(uint64), nil
+	}
+
+	index, found, err := nsm.findTypeDescriptor(prefix, preamble)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		nsm.txn.state.typeDescriptorIndexes.Store(cacheKey, index)
+		return index, nil
+	}
+	if !write {
+		return 0, errTypeDescriptorNotStored
+	}
+
+	// Not cached, as transaction may be discarded
+	indexb := make([]byte, binary.MaxVarintLen64)
+	err = nsm.txn.badgertxn.Set(joinKey(prefix, indexb[:binary.PutUvarint(indexb, index)]), preamble)
+	if err != nil {
+		return 0, err
+	}
+
+	return index, nil
+}
+
+// Returns index of stored type description equal to preamble, or the next
+// unused index if it is not found
+func (nsm *NamespaceMultiple[KeyT, ValueT]) findTypeDescriptor(prefix []byte, preamble []byte) (index uint64, found bool, err error) {
+	iteratorOptions := badger.DefaultIteratorOptions
+	iteratorOptions.Prefix = prefix
+	it := nsm.txn.badgertxn.NewIterator(iteratorOptions)
+	defer it.Close()
+
+	var maxIndex uint64
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+
+		index, n := binary.Uvarint(item.Key()[len(prefix):])
+		if n <= 0 {
+			return 0, false, errors.New("malformed type description key")
+		}
+		if index > maxIndex {
+			maxIndex = index
+		}
+
+		stored, err := item.ValueCopy(nil)
+		if err != nil {
+			return 0, false, err
+		}
+		if bytes.Equal(stored, preamble) {
+			return index, true, nil
+		}
+	}
+
+	return maxIndex + 1, false, nil
+}
+
+// Returns type description stored under index
+func (nsm *NamespaceMultiple[KeyT, ValueT]) typeDescriptor(index uint64) ([]byte, error) {
+	indexb := make([]byte, binary.MaxVarintLen64)
+	key := joinKey(nsm.txn.scopedKey(typeDescriptorsPrefix(nsm.name)), indexb[:binary.PutUvarint(indexb, index)])
+
+	if preamble, ok := nsm.txn.state.typeDescriptors.Load(string(key)); ok {
+		return preamble.([]byte), nil
+	}
+
+	item, err := nsm.txn.badgertxn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, fmt.Errorf("type description %v is not stored", index)
+		}
+
+		return nil, err
+	}
+
+	preamble, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	nsm.txn.state.typeDescriptors.Store(string(key), preamble)
+
+	return preamble, nil
+}
+
+// Drops cached type descriptions of namespace with passed name, after they are
+// deleted or moved
+func (state *dbState) forgetTypeDescriptors(name string) {
+	prefix := string(typeDescriptorsPrefix(name))
+	state.forgetTypeDescriptorsIf(func(key string) bool {
+		return strings.Contains(key, prefix)
+	})
+}
+
+// Drops cached type descriptions, which cache keys match, after they are
+// deleted by DropAll, DropTenant or LoadBackup. Cache keys start with stored
+// keys of type descriptions or their prefixes.
+func (state *dbState) forgetTypeDescriptorsIf(match func(key string) bool) {
+	for _, cache := range []*sync.Map{&state.typeDescriptors, &state.typeDescriptorIndexes} {
+		cache.Range(func(key, value any) bool {
+			if match(key.(string)) {
+				cache.Delete(key)
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package instorage
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+type descriptorPoint struct {
+	X, Y  int
+	Label string
+}
+
+// Returns total size of values stored in namespace
+func storedValueBytes(tb testing.TB, db *DB[Txn], name string) int {
+	tb.Helper()
+
+	size := 0
+	err := db.Badger().View(func(badgertxn *badger.Txn) error {
+		prefix := db.state.namespacePrefix(name)
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			size += int(it.Item().ValueSize())
+		}
+
+		return nil
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return size
+}
+
+func TestSharedTypeDescriptorRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, descriptorPoint](txn, "points").WithSharedTypeDescriptor()
+		for i := 0; i < 10; i++ {
+			err := nsm.Set(i, descriptorPoint{X: i, Y: -i, Label: "p"})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, descriptorPoint](txn, "points").WithSharedTypeDescriptor()
+
+		value, ok, err := nsm.Get(7)
+		if err != nil {
+			return err
+		}
+		if !ok || value != (descriptorPoint{X: 7, Y: -7, Label: "p"}) {
+			t.Errorf("Get returned %+v, %v", value, ok)
+		}
+
+		key, ok, err := nsm.FindKeyByValue(descriptorPoint{X: 3, Y: -3, Label: "p"})
+		if err != nil {
+			return err
+		}
+		if !ok || key != 3 {
+			t.Errorf("FindKeyByValue returned %v, %v", key, ok)
+		}
+
+		n := 0
+		err = nsm.Iter(func(key int, value descriptorPoint) (bool, error) {
+			n++
+			return false, nil
+		})
+		if n != 10 {
+			t.Errorf("Iter visited %v pairs", n)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedTypeDescriptorReadOnlyLookups(t *testing.T) {
+	db := openTestDB(t)
+
+	// Description is not stored yet, so lookups must not try to store it
+	err := db.View(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, descriptorPoint](txn, "points").WithSharedTypeDescriptor()
+
+		_, ok, err := nsm.FindKeyByValue(descriptorPoint{X: 1})
+		if err != nil {
+			return err
+		}
+		if ok {
+			t.Error("FindKeyByValue found value in empty namespace")
+		}
+
+		deleted, err := nsm.CompareAndDelete(1, descriptorPoint{X: 1})
+		if err != nil {
+			return err
+		}
+		if deleted {
+			t.Error("CompareAndDelete deleted value in empty namespace")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedTypeDescriptorShrinksValues(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		plain := NewNamespaceMultiple[int, descriptorPoint](txn, "plain")
+		shared := NewNamespaceMultiple[int, descriptorPoint](txn, "shared").WithSharedTypeDescriptor()
+		for i := 0; i < 100; i++ {
+			value := descriptorPoint{X: i, Y: i * 2, Label: "p"}
+			err := plain.Set(i, value)
+			if err != nil {
+				return err
+			}
+			err = shared.Set(i, value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainSize := storedValueBytes(t, db, "plain")
+	sharedSize := storedValueBytes(t, db, "shared")
+	if sharedSize*2 > plainSize {
+		t.Errorf("shared descriptor values take %v bytes, plain ones %v", sharedSize, plainSize)
+	}
+}
+
+// Reports stored bytes per entry with and without WithSharedTypeDescriptor
+func BenchmarkSharedTypeDescriptor(b *testing.B) {
+	for _, shared := range []bool{false, true} {
+		name := "plain"
+		if shared {
+			name = "shared"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			db := openTestDB(b)
+
+			b.ResetTimer()
+			// Values are written in batches, so big b.N fits transaction limits
+			for start := 0; start < b.N; start += 1000 {
+				err := db.Update(func(txn Txn) error {
+					nsm := NewNamespaceMultiple[int, descriptorPoint](txn, name)
+					if shared {
+						nsm.WithSharedTypeDescriptor()
+					}
+					for i := start; i < start+1000 && i < b.N; i++ {
+						err := nsm.Set(i, descriptorPoint{X: i, Y: i * 2, Label: "p"})
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(storedValueBytes(b, db, name))/float64(b.N), "bytes/entry")
+		})
+	}
+}
+
+// Sets point under key 1 of namespace points of tenant, or of database if
+// tenant is empty
+func setDescriptorPoint(t *testing.T, db *DB[Txn], tenant string, point descriptorPoint) {
+	t.Helper()
+
+	err := db.Update(func(txn Txn) error {
+		if tenant != "" {
+			txn = txn.Tenant(tenant)
+		}
+		return NewNamespaceMultiple[int, descriptorPoint](txn, "points").WithSharedTypeDescriptor().Set(1, point)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks that key 1 of namespace points of tenant stores point
+func checkDescriptorPoint(t *testing.T, db *DB[Txn], tenant string, want descriptorPoint) {
+	t.Helper()
+
+	err := db.View(func(txn Txn) error {
+		if tenant != "" {
+			txn = txn.Tenant(tenant)
+		}
+		value, ok, err := NewNamespaceMultiple[int, descriptorPoint](txn, "points").WithSharedTypeDescriptor().Get(1)
+		if err != nil {
+			return err
+		}
+		if !ok || value != want {
+			t.Errorf("Get(1) = %+v, %v, expected %+v", value, ok, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedTypeDescriptorAfterDrop(t *testing.T) {
+	for _, tenant := range []string{"", "tenant"} {
+		dir := t.TempDir()
+		db, err := Open(dir, func(txn Txn) Txn { return txn })
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The second write finds stored description and caches its index
+		setDescriptorPoint(t, db, tenant, descriptorPoint{X: 1})
+		setDescriptorPoint(t, db, tenant, descriptorPoint{X: 1})
+
+		if tenant == "" {
+			err = db.DropAll()
+		} else {
+			err = db.DropTenant(tenant)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := descriptorPoint{X: 2, Label: "after drop"}
+		setDescriptorPoint(t, db, tenant, want)
+
+		db = reopenTestDB(t, db, dir)
+		checkDescriptorPoint(t, db, tenant, want)
+	}
+}
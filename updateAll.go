@@ -0,0 +1,81 @@
+package instorage
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Applies fn to every value of NamespaceMultiple with passed name and rewrites
+// values, for which it returns changed == true. Changed values are written
+// with write batch, which commits them in chunks, so namespace may be bigger
+// than a single transaction allows. Values are read and written in separate
+// transactions, so it should not be called while namespace is written by other
+// transactions. If fn returns error, updating stops and some of already
+// changed values may be written. Expiration of keys is kept. Values must be
+// gob encoded. Returns number of rewritten values.
+func UpdateAll[TxnAPIT any, ValueT any](db *DB[TxnAPIT], name string, fn func(value ValueT) (newValue ValueT, changed bool, err error)) (updated int, err error) {
+	Txn{state: db.state}.validateNamespaceName(name)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefix := db.state.namespacePrefix(name)
+
+	wb := db.badgerdb.NewWriteBatch()
+	defer wb.Cancel()
+
+	err = db.badgerdb.View(func(badgertxn *badger.Txn) error {
+		it := badgertxn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			var valuePtr *ValueT
+			err := item.Value(func(valueb []byte) error {
+				var err error
+				valuePtr, err = decodeValueGob[ValueT](valueb)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			newValue, changed, err := fn(*valuePtr)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				continue
+			}
+
+			valueb, err := encodeValueGob(newValue)
+			if err != nil {
+				return err
+			}
+
+			entry := badger.NewEntry(item.KeyCopy(nil), valueb)
+			entry.ExpiresAt = item.ExpiresAt()
+			entry.UserMeta = item.UserMeta()
+
+			err = wb.SetEntry(entry)
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("UpdateAll `%v`: %w", name, err)
+	}
+
+	err = wb.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("UpdateAll `%v`: %w", name, err)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,33 @@
+package instorage
+
+import "fmt"
+
+// Value stored with its version by SetIfVersion. Version of stored value
+// starts from 1, zero version means value does not exist.
+type Versioned[ValueT any] struct {
+	Version uint64
+	Value   ValueT
+}
+
+// Sets value under a key with the next version, only if stored version of
+// value equals expectedVersion, like ETag check. Zero expectedVersion means
+// key must not exist. Otherwise returns ErrVersionMismatch and leaves value
+// unchanged. Concurrent transactions setting the same key conflict, so only
+// one of them is committed.
+func SetIfVersion[KeyT comparable, ValueT any](nsm *NamespaceMultiple[KeyT, Versioned[ValueT]], key KeyT, value ValueT, expectedVersion uint64) (newVersion uint64, err error) {
+	current, _, err := nsm.Get(key)
+	if err != nil {
+		return 0, fmt.Errorf("SetIfVersion `%v`: %w", nsm.name, err)
+	}
+	if current.Version != expectedVersion {
+		return 0, fmt.Errorf("SetIfVersion `%v`: %w: expected %v, stored %v", nsm.name, ErrVersionMismatch, expectedVersion, current.Version)
+	}
+
+	newVersion = current.Version + 1
+	err = nsm.Set(key, Versioned[ValueT]{Version: newVersion, Value: value})
+	if err != nil {
+		return 0, fmt.Errorf("SetIfVersion `%v`: %w", nsm.name, err)
+	}
+
+	return newVersion, nil
+}
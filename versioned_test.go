@@ -0,0 +1,52 @@
+package instorage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetIfVersion(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[string, Versioned[string]](txn, "documents")
+
+		version, err := SetIfVersion(nsm, "doc", "first", 0)
+		if err != nil {
+			return err
+		}
+		if version != 1 {
+			t.Errorf("first version is %v, expected 1", version)
+		}
+
+		_, err = SetIfVersion(nsm, "doc", "stale", 0)
+		if !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("setting existing key with zero version returned %v, expected ErrVersionMismatch", err)
+		}
+
+		version, err = SetIfVersion(nsm, "doc", "second", 1)
+		if err != nil {
+			return err
+		}
+		if version != 2 {
+			t.Errorf("second version is %v, expected 2", version)
+		}
+
+		_, err = SetIfVersion(nsm, "doc", "stale", 1)
+		if !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("setting with outdated version returned %v, expected ErrVersionMismatch", err)
+		}
+
+		stored, ok, err := nsm.Get("doc")
+		if err != nil {
+			return err
+		}
+		if !ok || stored != (Versioned[string]{Version: 2, Value: "second"}) {
+			t.Errorf("stored %+v, %v, expected version 2 with value second", stored, ok)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
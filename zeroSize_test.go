@@ -0,0 +1,83 @@
+package instorage
+
+import "testing"
+
+// Stores keys 0..n-1 with struct{} values in namespace
+func setZeroSizeValues(t *testing.T, db *DB[Txn], name string, n int) {
+	t.Helper()
+
+	err := db.Update(func(txn Txn) error {
+		nsm := NewNamespaceMultiple[int, struct{}](txn, name)
+		for i := 0; i < n; i++ {
+			err := nsm.Set(i, struct{}{})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamScanZeroSizeValues(t *testing.T) {
+	db := openTestDB(t)
+	setZeroSizeValues(t, db, "set", 10)
+
+	seen := map[int]bool{}
+	err := StreamScan(db, "set", func(key int, value struct{}) error {
+		seen[key] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 10 {
+		t.Fatalf("scanned %v keys, expected 10", len(seen))
+	}
+}
+
+func TestUpdateAllZeroSizeValues(t *testing.T) {
+	db := openTestDB(t)
+	setZeroSizeValues(t, db, "set", 10)
+
+	updated, err := UpdateAll(db, "set", func(value struct{}) (struct{}, bool, error) {
+		return value, true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 10 {
+		t.Fatalf("updated %v values, expected 10", updated)
+	}
+
+	err = db.View(func(txn Txn) error {
+		_, ok, err := NewNamespaceMultiple[int, struct{}](txn, "set").Get(3)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			t.Fatal("key 3 is missing after UpdateAll")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactNamespaceDroppingZeroSizeValues(t *testing.T) {
+	db := openTestDB(t)
+	setZeroSizeValues(t, db, "set", 10)
+
+	dropped, err := CompactNamespaceDropping(db, "set", nil, func(key int, value struct{}) bool {
+		return key%2 == 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 5 {
+		t.Fatalf("dropped %v pairs, expected 5", dropped)
+	}
+}